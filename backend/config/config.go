@@ -2,11 +2,19 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
 	DatabaseDSN string
 	ServerPort  string
+	// RewriteLocalhost maps localhost LLM URLs to host.docker.internal so
+	// the backend container can reach models running on the host.
+	RewriteLocalhost bool
+	// TaskRetention is how long finished tasks are kept, zero keeps them
+	// forever.
+	TaskRetention time.Duration
 }
 
 func LoadConfig() *Config {
@@ -20,8 +28,17 @@ func LoadConfig() *Config {
 		port = "8080"
 	}
 
+	retentionHours := 24 * 30
+	if v := os.Getenv("TASK_RETENTION_HOURS"); v != "" {
+		if hours, err := strconv.Atoi(v); err == nil {
+			retentionHours = hours
+		}
+	}
+
 	return &Config{
-		DatabaseDSN: dsn,
-		ServerPort:  port,
+		DatabaseDSN:      dsn,
+		ServerPort:       port,
+		RewriteLocalhost: os.Getenv("LLM_REWRITE_LOCALHOST") != "false",
+		TaskRetention:    time.Duration(retentionHours) * time.Hour,
 	}
 }
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"codeagent-backend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var experimentService = new(services.ExperimentService)
+
+func GetExperiments(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "30"))
+	if pageSize > 30 {
+		pageSize = 30
+	}
+
+	filter := services.ExperimentFilter{
+		ProjectID: c.Query("project_id"),
+		PromptID:  c.Query("prompt_id"),
+		TaskID:    c.Query("task_id"),
+		Kind:      c.Query("kind"),
+		ConfigID:  c.Query("config_id"),
+	}
+	experiments, total, err := experimentService.GetExperiments(filter, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":     experiments,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetExperiment returns an experiment with a summary of its results
+func GetExperiment(c *gin.Context) {
+	experiment, err := experimentService.GetExperiment(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Experiment not found"})
+		return
+	}
+
+	stats, err := experimentService.GetExperimentStats(experiment.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"experiment": experiment, "stats": stats})
+}
+
+func GetExperimentResults(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "30"))
+	if pageSize > 30 {
+		pageSize = 30
+	}
+
+	results, total, err := experimentService.GetExperimentResults(c.Param("id"), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":     results,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// PinBaseline makes an experiment the baseline its prompt's later
+// experiments are gated against
+func PinBaseline(c *gin.Context) {
+	if _, err := experimentService.GetExperiment(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Experiment not found"})
+		return
+	}
+
+	prompt, err := experimentService.PinBaseline(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, prompt)
+}
+
+// GetGateVerdict compares an experiment with the baseline of its prompt
+func GetGateVerdict(c *gin.Context) {
+	if _, err := experimentService.GetExperiment(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Experiment not found"})
+		return
+	}
+
+	verdict, err := experimentService.GateExperiment(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, verdict)
+}
+
+// GetFlakiness reports pass@k, pass-all-k and the consistency of every
+// input of an experiment sampled several times
+func GetFlakiness(c *gin.Context) {
+	experiment, err := experimentService.GetExperiment(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Experiment not found"})
+		return
+	}
+
+	k, _ := strconv.Atoi(c.DefaultQuery("k", "0"))
+	if k < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "k must not be negative"})
+		return
+	}
+
+	report, err := experimentService.GetFlakiness(experiment.ID, k)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// CompareExperiments reports the results that changed between experiments a
+// and b
+func CompareExperiments(c *gin.Context) {
+	a, b := c.Query("a"), c.Query("b")
+	if a == "" || b == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a and b are required"})
+		return
+	}
+
+	for _, id := range []string{a, b} {
+		if _, err := experimentService.GetExperiment(id); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Experiment " + id + " not found"})
+			return
+		}
+	}
+
+	comparison, err := experimentService.CompareExperiments(a, b)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, comparison)
+}
@@ -18,6 +18,15 @@ func CreateLLMConfig(c *gin.Context) {
 		return
 	}
 
+	if err := services.ValidateProvider(config.Provider); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateRetryStatusCodes(config.RetryStatusCodes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := llmConfigService.CreateLLMConfig(&config); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -60,6 +69,15 @@ func UpdateLLMConfig(c *gin.Context) {
 		return
 	}
 
+	if err := services.ValidateProvider(input.Provider); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateRetryStatusCodes(input.RetryStatusCodes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := llmConfigService.UpdateLLMConfig(config, input); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -32,6 +32,8 @@ func GenerateLLMTestCases(c *gin.Context) {
 type RunRequest struct {
 	TestCaseIDs []uint `json:"test_case_ids"`
 	ConfigID    uint   `json:"config_id"`
+	Concurrency int    `json:"concurrency"` // Workers, defaults to the config's max concurrency
+	Repetitions int    `json:"repetitions"` // Samples taken of each test case, defaults to one, runs only
 }
 
 func RunLLMTestCases(c *gin.Context) {
@@ -40,8 +42,12 @@ func RunLLMTestCases(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := services.ValidateRepetitions(req.Repetitions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	taskID, err := llmTestCaseService.RunLLMTestCases(req.TestCaseIDs, req.ConfigID)
+	taskID, err := llmTestCaseService.RunLLMTestCases(req.TestCaseIDs, req.ConfigID, req.Concurrency, req.Repetitions, requestUser(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -56,8 +62,12 @@ func EvaluateLLMTestCases(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.Repetitions != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "repetitions only apply to runs"})
+		return
+	}
 
-	taskID, err := llmTestCaseService.EvaluateLLMTestCases(req.TestCaseIDs, req.ConfigID)
+	taskID, err := llmTestCaseService.EvaluateLLMTestCases(req.TestCaseIDs, req.ConfigID, req.Concurrency, requestUser(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -89,6 +99,16 @@ func GetLLMTestCases(c *gin.Context) {
 	})
 }
 
+func GetCostSummary(c *gin.Context) {
+	summary, err := llmTestCaseService.GetCostSummary(c.Query("task_id"), c.Query("prompt_id"), c.Query("project_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
 func DeleteLLMTestCase(c *gin.Context) {
 	if err := llmTestCaseService.DeleteLLMTestCase(c.Param("id")); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -119,8 +139,10 @@ func BatchDeleteLLMTestCases(c *gin.Context) {
 }
 
 type RunFromDefinitionsRequest struct {
-	PromptID uint `json:"prompt_id"`
-	ConfigID uint `json:"config_id"`
+	PromptID    uint `json:"prompt_id"`
+	ConfigID    uint `json:"config_id"`
+	Concurrency int  `json:"concurrency"` // Workers, defaults to the config's max concurrency
+	Repetitions int  `json:"repetitions"` // Samples taken of each definition, defaults to one
 }
 
 func RunLLMTestCasesFromDefinitions(c *gin.Context) {
@@ -129,8 +151,12 @@ func RunLLMTestCasesFromDefinitions(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := services.ValidateRepetitions(req.Repetitions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	taskID, err := llmTestCaseService.RunLLMTestCasesFromDefinitions(req.PromptID, req.ConfigID)
+	taskID, err := llmTestCaseService.RunLLMTestCasesFromDefinitions(req.PromptID, req.ConfigID, req.Concurrency, req.Repetitions, requestUser(c))
 	if err != nil {
 		if err.Error() == "no test cases found for this project" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -143,6 +169,47 @@ func RunLLMTestCasesFromDefinitions(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"task_id": taskID, "message": "Run from definitions started"})
 }
 
+type RunMatrixRequest struct {
+	ProjectID     uint   `json:"project_id"`
+	PromptIDs     []uint `json:"prompt_ids"`
+	ConfigIDs     []uint `json:"config_ids"`
+	JudgeConfigID uint   `json:"judge_config_id"` // Judges every cell, defaults to the cell's own config
+	Concurrency   int    `json:"concurrency"`     // Workers, defaults to the sum of the configs' max concurrency
+	Repetitions   int    `json:"repetitions"`     // Samples taken of each definition in each cell, defaults to one
+}
+
+func RunLLMTestCasesMatrix(c *gin.Context) {
+	var req RunMatrixRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	taskID, err := llmTestCaseService.RunLLMTestCasesMatrix(req.ProjectID, req.PromptIDs, req.ConfigIDs, req.JudgeConfigID, req.Concurrency, req.Repetitions, requestUser(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"task_id": taskID, "message": "Matrix run started"})
+}
+
+// GetMatrix returns the pass rate, latency and cost of every cell of a
+// matrix run
+func GetMatrix(c *gin.Context) {
+	report, err := llmTestCaseService.GetMatrix(c.Query("task_id"))
+	if err != nil {
+		if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
 func UpdateLLMTestCase(c *gin.Context) {
 	testCase, err := llmTestCaseService.GetLLMTestCase(c.Param("id"))
 	if err != nil {
@@ -158,6 +225,7 @@ func UpdateLLMTestCase(c *gin.Context) {
 
 	// Only allow updating specific fields if needed, but for now allow full update
 	testCase.IsPass = input.IsPass
+	testCase.Critical = input.Critical
 	// Allow updating evaluation text too if user wants to add notes
 	if input.Evaluation != "" {
 		testCase.Evaluation = input.Evaluation
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"codeagent-backend/models"
+	"codeagent-backend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var modelPriceService = new(services.ModelPriceService)
+
+func CreateModelPrice(c *gin.Context) {
+	var price models.ModelPrice
+	if err := c.ShouldBindJSON(&price); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if price.ModelName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "model_name is required"})
+		return
+	}
+	if err := services.ValidateProvider(price.Provider); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := modelPriceService.CreateModelPrice(&price); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, price)
+}
+
+func GetModelPrices(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "30"))
+	if pageSize > 30 {
+		pageSize = 30
+	}
+
+	prices, total, err := modelPriceService.GetModelPrices(page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":     prices,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func UpdateModelPrice(c *gin.Context) {
+	price, err := modelPriceService.GetModelPrice(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model price not found"})
+		return
+	}
+
+	var input models.ModelPrice
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.ModelName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "model_name is required"})
+		return
+	}
+	if err := services.ValidateProvider(input.Provider); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	price.Provider = input.Provider
+	price.ModelName = input.ModelName
+	price.InputPricePerM = input.InputPricePerM
+	price.OutputPricePerM = input.OutputPricePerM
+	price.Tags = input.Tags
+
+	if err := modelPriceService.UpdateModelPrice(price); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, price)
+}
+
+func DeleteModelPrice(c *gin.Context) {
+	price, err := modelPriceService.GetModelPrice(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model price not found"})
+		return
+	}
+
+	if err := modelPriceService.DeleteModelPrice(price); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Model price deleted"})
+}
+
+func BatchDeleteModelPrices(c *gin.Context) {
+	var req struct {
+		IDs []uint `json:"ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No IDs provided"})
+		return
+	}
+
+	if err := modelPriceService.BatchDeleteModelPrices(req.IDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Model prices deleted"})
+}
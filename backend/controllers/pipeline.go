@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"codeagent-backend/models"
+	"codeagent-backend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var pipelineService = &services.PipelineService{
+	LLMTestCaseService: &services.LLMTestCaseService{LLMService: new(services.LLMService)},
+}
+
+func CreatePipeline(c *gin.Context) {
+	var pipeline models.Pipeline
+	if err := c.ShouldBindJSON(&pipeline); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if pipeline.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if err := services.ValidatePipelineStages(pipeline.Stages); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := pipelineService.CreatePipeline(&pipeline); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, pipeline)
+}
+
+func GetPipelines(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "30"))
+	if pageSize > 30 {
+		pageSize = 30
+	}
+
+	pipelines, total, err := pipelineService.GetPipelines(c.Query("project_id"), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":     pipelines,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func GetPipeline(c *gin.Context) {
+	pipeline, err := pipelineService.GetPipeline(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pipeline not found"})
+		return
+	}
+	c.JSON(http.StatusOK, pipeline)
+}
+
+func UpdatePipeline(c *gin.Context) {
+	pipeline, err := pipelineService.GetPipeline(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pipeline not found"})
+		return
+	}
+
+	var input models.Pipeline
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if err := services.ValidatePipelineStages(input.Stages); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pipeline.ProjectID = input.ProjectID
+	pipeline.Name = input.Name
+	pipeline.Description = input.Description
+	pipeline.PromptIDs = input.PromptIDs
+	pipeline.Stages = input.Stages
+
+	if err := pipelineService.UpdatePipeline(pipeline); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, pipeline)
+}
+
+func DeletePipeline(c *gin.Context) {
+	pipeline, err := pipelineService.GetPipeline(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pipeline not found"})
+		return
+	}
+
+	if err := pipelineService.DeletePipeline(pipeline); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Pipeline deleted"})
+}
+
+func BatchDeletePipelines(c *gin.Context) {
+	var req struct {
+		IDs []uint `json:"ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No IDs provided"})
+		return
+	}
+
+	if err := pipelineService.BatchDeletePipelines(req.IDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Pipelines deleted"})
+}
+
+// RunPipeline starts a task running the stages of a pipeline
+func RunPipeline(c *gin.Context) {
+	if _, err := pipelineService.GetPipeline(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pipeline not found"})
+		return
+	}
+
+	taskID, err := pipelineService.RunPipeline(c.Param("id"), requestUser(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"task_id": taskID, "message": "Pipeline started"})
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"codeagent-backend/models"
+	"codeagent-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var playgroundService = &services.PlaygroundService{LLMService: new(services.LLMService)}
+
+type PlaygroundRunRequest struct {
+	PromptID     uint              `json:"prompt_id"`
+	Content      string            `json:"content"` // Raw prompt content, used when prompt_id is empty
+	Input        string            `json:"input"`
+	Conversation []models.ChatTurn `json:"conversation"` // Prior turns for multi-turn prompts
+	ConfigIDs    []uint            `json:"config_ids"`
+	Save         bool              `json:"save"` // Persist results as LLM test cases
+}
+
+func RunPlayground(c *gin.Context) {
+	var req PlaygroundRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.PromptID == 0 && req.Content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "prompt_id or content is required"})
+		return
+	}
+	if err := services.ValidateConversation(req.Conversation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.ConfigIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No config IDs provided"})
+		return
+	}
+	if req.Save && req.PromptID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "prompt_id is required to save results"})
+		return
+	}
+
+	results, err := playgroundService.Run(c.Request.Context(), req.PromptID, req.Content, req.Conversation, req.Input, req.ConfigIDs, req.Save)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
@@ -57,6 +57,10 @@ func UpdatePrompt(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := services.ValidateBaseline(*prompt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := promptService.UpdatePrompt(prompt); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -120,3 +124,54 @@ func BatchGeneratePrompts(c *gin.Context) {
 
 	c.JSON(http.StatusOK, createdPrompts)
 }
+
+// StreamPromptRun runs a prompt on a single input and streams the output to
+// the client as Server-Sent Events: "delta" events carry chunks of content,
+// followed by a final "done" event with the full output or an "error" event.
+func StreamPromptRun(c *gin.Context) {
+	prompt, err := promptService.GetPrompt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+		return
+	}
+
+	var req struct {
+		ConfigID     uint              `json:"config_id"`
+		Input        string            `json:"input"`
+		Conversation []models.ChatTurn `json:"conversation"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateConversation(req.Conversation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	config, err := llmConfigService.GetLLMConfig(strconv.FormatUint(uint64(req.ConfigID), 10))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Config not found"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable nginx proxy buffering
+
+	ctx := c.Request.Context()
+	resp, err := promptService.LLMService.StreamPrompt(ctx, *config, prompt.Content, req.Conversation, req.Input, func(delta string) error {
+		c.SSEvent("delta", gin.H{"content": delta})
+		c.Writer.Flush()
+		return ctx.Err()
+	})
+	if err != nil {
+		c.SSEvent("error", gin.H{"error": err.Error()})
+		c.Writer.Flush()
+		return
+	}
+
+	c.SSEvent("done", gin.H{"output": resp.Content, "usage": resp.Usage})
+	c.Writer.Flush()
+}
@@ -3,10 +3,184 @@ package controllers
 import (
 	"codeagent-backend/services"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+var taskService = new(services.TaskService)
+
+// requestUser identifies who made a request: the X-User header set by the
+// frontend or a proxy, else the client IP.
+func requestUser(c *gin.Context) string {
+	if user := c.GetHeader("X-User"); user != "" {
+		return user
+	}
+	return c.ClientIP()
+}
+
+func GetTasks(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "30"))
+	if pageSize > 30 {
+		pageSize = 30
+	}
+
+	filter := services.TaskFilter{
+		Status:    c.Query("status"),
+		Type:      c.Query("type"),
+		ProjectID: c.Query("project_id"),
+		PromptID:  c.Query("prompt_id"),
+		CreatedBy: c.Query("created_by"),
+	}
+	var err error
+	if filter.From, err = parseTimeQuery(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from: " + err.Error()})
+		return
+	}
+	if filter.To, err = parseTimeQuery(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to: " + err.Error()})
+		return
+	}
+	if len(c.Query("to")) == len("2006-01-02") {
+		// A plain end date includes the whole day
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+
+	tasks, total, err := taskService.GetTasks(filter, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":     tasks,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// parseTimeQuery accepts RFC 3339 timestamps or plain dates.
+func parseTimeQuery(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+func GetTask(c *gin.Context) {
+	task, exists := services.GlobalTaskManager.GetTask(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &task)
+}
+
+// GetTaskItems lists the outcome of every finished item of a task
+func GetTaskItems(c *gin.Context) {
+	if _, exists := services.GlobalTaskManager.GetTask(c.Param("id")); !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "30"))
+	if pageSize > 30 {
+		pageSize = 30
+	}
+
+	items, total, err := taskService.GetTaskItems(c.Param("id"), c.Query("status"), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":     items,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// taskEventsHeartbeat keeps idle event streams from being closed by proxies.
+const taskEventsHeartbeat = 15 * time.Second
+
+// StreamTaskEvents pushes a task's progress as Server-Sent Events: "progress"
+// with the task after every change, "item" for every completed item, and
+// "done" with the final state of the task.
+func StreamTaskEvents(c *gin.Context) {
+	id := c.Param("id")
+	events, unsubscribe, ok := services.GlobalTaskManager.Subscribe(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable nginx proxy buffering
+
+	// Subscribing first means no change is missed between the two
+	if task, ok := services.GlobalTaskManager.GetTask(id); ok {
+		c.SSEvent("progress", &task)
+		c.Writer.Flush()
+	}
+
+	heartbeat := time.NewTicker(taskEventsHeartbeat)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			c.SSEvent("ping", gin.H{})
+			c.Writer.Flush()
+		case event, open := <-events:
+			if !open {
+				if task, ok := services.GlobalTaskManager.GetTask(id); ok {
+					c.SSEvent("done", &task)
+					c.Writer.Flush()
+				}
+				return
+			}
+			if event.Item != nil {
+				c.SSEvent("item", event.Item)
+			} else {
+				c.SSEvent("progress", event.Task)
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func RetryFailedTaskItems(c *gin.Context) {
+	var req struct {
+		TaskID string `json:"task_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := llmTestCaseService.RetryFailedItems(req.TaskID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"task_id": req.TaskID, "message": "Retrying failed items"})
+}
+
 func GetTaskStatus(c *gin.Context) {
 	taskID := c.Query("task_id")
 	if taskID == "" {
@@ -20,7 +194,7 @@ func GetTaskStatus(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, task)
+	c.JSON(http.StatusOK, &task)
 }
 
 func StopTask(c *gin.Context) {
@@ -35,3 +209,35 @@ func StopTask(c *gin.Context) {
 	services.GlobalTaskManager.StopTask(req.TaskID)
 	c.JSON(http.StatusOK, gin.H{"message": "Task stopped"})
 }
+
+func PauseTask(c *gin.Context) {
+	var req struct {
+		TaskID string `json:"task_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.GlobalTaskManager.PauseTask(req.TaskID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Task paused"})
+}
+
+func ResumeTask(c *gin.Context) {
+	var req struct {
+		TaskID string `json:"task_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.GlobalTaskManager.ResumeTask(req.TaskID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"task_id": req.TaskID, "message": "Task resumed"})
+}
@@ -17,6 +17,10 @@ func CreateTestCase(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := services.ValidateConversation(testCase.Conversation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	created, err := testCaseService.CreateTestCase(&testCase)
 	if err != nil {
@@ -83,15 +87,19 @@ func UpdateTestCase(c *gin.Context) {
 		return
 	}
 
-	// Backup original input to check if it changed
-	originalInput := testCase.Input
+	// Backup original input hash to check if the input or conversation changed
+	originalMD5 := testCase.InputMD5
 
 	if err := c.ShouldBindJSON(testCase); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := services.ValidateConversation(testCase.Conversation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	updated, err := testCaseService.UpdateTestCase(testCase, originalInput)
+	updated, err := testCaseService.UpdateTestCase(testCase, originalMD5)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -3,17 +3,27 @@ package main
 import (
 	"codeagent-backend/config"
 	"codeagent-backend/routes"
+	"codeagent-backend/services"
 	"codeagent-backend/utils"
 	"fmt"
+	"log"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
 
+	services.RewriteLocalhost = cfg.RewriteLocalhost
+
 	// Initialize database
 	utils.InitDB(cfg.DatabaseDSN)
 
+	// Tasks left running by a previous process can't be running anymore
+	if err := services.GlobalTaskManager.MarkInterruptedTasks(); err != nil {
+		log.Printf("Failed to mark interrupted tasks: %v", err)
+	}
+	services.GlobalTaskManager.StartJanitor(cfg.TaskRetention)
+
 	// Setup router
 	r := routes.SetupRouter()
 
@@ -0,0 +1 @@
+ALTER TABLE `llm_configs` DROP COLUMN `provider`;
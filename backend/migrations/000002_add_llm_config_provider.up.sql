@@ -0,0 +1 @@
+ALTER TABLE `llm_configs` ADD COLUMN `provider` varchar(32) DEFAULT NULL AFTER `name`;
@@ -0,0 +1,3 @@
+ALTER TABLE `llm_configs`
+  DROP COLUMN `azure_deployment`,
+  DROP COLUMN `api_version`;
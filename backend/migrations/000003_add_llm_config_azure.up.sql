@@ -0,0 +1,3 @@
+ALTER TABLE `llm_configs`
+  ADD COLUMN `azure_deployment` longtext,
+  ADD COLUMN `api_version` varchar(32) DEFAULT NULL;
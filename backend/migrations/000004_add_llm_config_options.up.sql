@@ -0,0 +1 @@
+ALTER TABLE `llm_configs` ADD COLUMN `options` text;
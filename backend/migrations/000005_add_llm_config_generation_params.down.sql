@@ -0,0 +1,7 @@
+ALTER TABLE `llm_configs`
+  DROP COLUMN `max_tokens`,
+  DROP COLUMN `top_p`,
+  DROP COLUMN `frequency_penalty`,
+  DROP COLUMN `presence_penalty`,
+  DROP COLUMN `stop`,
+  DROP COLUMN `seed`;
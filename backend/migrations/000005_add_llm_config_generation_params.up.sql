@@ -0,0 +1,7 @@
+ALTER TABLE `llm_configs`
+  ADD COLUMN `max_tokens` bigint DEFAULT NULL,
+  ADD COLUMN `top_p` double DEFAULT NULL,
+  ADD COLUMN `frequency_penalty` double DEFAULT NULL,
+  ADD COLUMN `presence_penalty` double DEFAULT NULL,
+  ADD COLUMN `stop` text,
+  ADD COLUMN `seed` bigint DEFAULT NULL;
@@ -0,0 +1 @@
+ALTER TABLE `llm_configs` ADD COLUMN `timeout_seconds` bigint DEFAULT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE `test_cases` DROP COLUMN `conversation`;
+ALTER TABLE `llm_test_cases` DROP COLUMN `conversation`;
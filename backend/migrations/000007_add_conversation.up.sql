@@ -0,0 +1,2 @@
+ALTER TABLE `test_cases` ADD COLUMN `conversation` text AFTER `input`;
+ALTER TABLE `llm_test_cases` ADD COLUMN `conversation` text AFTER `input`;
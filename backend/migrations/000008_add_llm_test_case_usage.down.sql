@@ -0,0 +1,8 @@
+ALTER TABLE `llm_test_cases`
+  DROP COLUMN `prompt_tokens`,
+  DROP COLUMN `completion_tokens`,
+  DROP COLUMN `latency_ms`,
+  DROP COLUMN `finish_reason`,
+  DROP COLUMN `eval_prompt_tokens`,
+  DROP COLUMN `eval_completion_tokens`,
+  DROP COLUMN `eval_latency_ms`;
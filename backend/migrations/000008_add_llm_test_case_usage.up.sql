@@ -0,0 +1,8 @@
+ALTER TABLE `llm_test_cases`
+  ADD COLUMN `prompt_tokens` bigint DEFAULT NULL,
+  ADD COLUMN `completion_tokens` bigint DEFAULT NULL,
+  ADD COLUMN `latency_ms` bigint DEFAULT NULL,
+  ADD COLUMN `finish_reason` varchar(32) DEFAULT NULL,
+  ADD COLUMN `eval_prompt_tokens` bigint DEFAULT NULL,
+  ADD COLUMN `eval_completion_tokens` bigint DEFAULT NULL,
+  ADD COLUMN `eval_latency_ms` bigint DEFAULT NULL;
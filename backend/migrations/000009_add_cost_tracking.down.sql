@@ -0,0 +1,9 @@
+ALTER TABLE `llm_test_cases`
+  DROP KEY `idx_llm_test_cases_task_id`,
+  DROP KEY `idx_llm_test_cases_eval_task_id`,
+  DROP COLUMN `task_id`,
+  DROP COLUMN `eval_task_id`,
+  DROP COLUMN `cost`,
+  DROP COLUMN `eval_cost`;
+
+DROP TABLE IF EXISTS `model_prices`;
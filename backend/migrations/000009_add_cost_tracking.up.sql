@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS `model_prices` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) DEFAULT NULL,
+  `updated_at` datetime(3) DEFAULT NULL,
+  `deleted_at` datetime(3) DEFAULT NULL,
+  `provider` varchar(32) DEFAULT NULL,
+  `model_name` varchar(191) DEFAULT NULL,
+  `input_price_per_m` double DEFAULT NULL,
+  `output_price_per_m` double DEFAULT NULL,
+  `tags` longtext,
+  PRIMARY KEY (`id`),
+  KEY `idx_model_prices_deleted_at` (`deleted_at`),
+  KEY `idx_model_prices_lookup` (`provider`,`model_name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE `llm_test_cases`
+  ADD COLUMN `task_id` varchar(36) DEFAULT NULL,
+  ADD COLUMN `eval_task_id` varchar(36) DEFAULT NULL,
+  ADD COLUMN `cost` double DEFAULT NULL,
+  ADD COLUMN `eval_cost` double DEFAULT NULL,
+  ADD KEY `idx_llm_test_cases_task_id` (`task_id`),
+  ADD KEY `idx_llm_test_cases_eval_task_id` (`eval_task_id`);
@@ -0,0 +1,9 @@
+ALTER TABLE `llm_test_cases`
+  DROP COLUMN `attempts`,
+  DROP COLUMN `eval_attempts`;
+
+ALTER TABLE `llm_configs`
+  DROP COLUMN `max_attempts`,
+  DROP COLUMN `retry_base_delay_ms`,
+  DROP COLUMN `retry_jitter`,
+  DROP COLUMN `retry_status_codes`;
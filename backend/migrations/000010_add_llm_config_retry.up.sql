@@ -0,0 +1,9 @@
+ALTER TABLE `llm_configs`
+  ADD COLUMN `max_attempts` bigint DEFAULT NULL,
+  ADD COLUMN `retry_base_delay_ms` bigint DEFAULT NULL,
+  ADD COLUMN `retry_jitter` double DEFAULT NULL,
+  ADD COLUMN `retry_status_codes` varchar(64) DEFAULT NULL;
+
+ALTER TABLE `llm_test_cases`
+  ADD COLUMN `attempts` bigint DEFAULT NULL,
+  ADD COLUMN `eval_attempts` bigint DEFAULT NULL;
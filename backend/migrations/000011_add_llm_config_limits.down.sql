@@ -0,0 +1,4 @@
+ALTER TABLE `llm_configs`
+  DROP COLUMN `requests_per_minute`,
+  DROP COLUMN `tokens_per_minute`,
+  DROP COLUMN `max_concurrency`;
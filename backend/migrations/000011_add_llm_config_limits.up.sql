@@ -0,0 +1,4 @@
+ALTER TABLE `llm_configs`
+  ADD COLUMN `requests_per_minute` bigint DEFAULT NULL,
+  ADD COLUMN `tokens_per_minute` bigint DEFAULT NULL,
+  ADD COLUMN `max_concurrency` bigint DEFAULT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `task_items`;
+DROP TABLE IF EXISTS `tasks`;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS `tasks` (
+  `id` varchar(36) NOT NULL,
+  `type` varchar(32) DEFAULT NULL,
+  `status` varchar(16) DEFAULT NULL,
+  `progress` bigint DEFAULT NULL,
+  `total` bigint DEFAULT NULL,
+  `message` longtext,
+  `result` text,
+  `error` text,
+  `params` text,
+  `created_at` datetime(3) DEFAULT NULL,
+  `updated_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_tasks_type` (`type`),
+  KEY `idx_tasks_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `task_items` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `task_id` varchar(36) DEFAULT NULL,
+  `item_index` bigint DEFAULT NULL,
+  `ref_id` bigint unsigned DEFAULT NULL,
+  `error` text,
+  `created_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_task_items_item` (`task_id`,`item_index`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
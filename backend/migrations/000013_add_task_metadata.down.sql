@@ -0,0 +1,8 @@
+ALTER TABLE `tasks`
+  DROP KEY `idx_tasks_created_at`,
+  DROP KEY `idx_tasks_project_id`,
+  DROP KEY `idx_tasks_prompt_id`,
+  DROP COLUMN `created_by`,
+  DROP COLUMN `project_id`,
+  DROP COLUMN `prompt_id`,
+  DROP COLUMN `config_id`;
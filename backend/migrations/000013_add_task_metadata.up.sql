@@ -0,0 +1,8 @@
+ALTER TABLE `tasks`
+  ADD COLUMN `created_by` varchar(191) DEFAULT NULL,
+  ADD COLUMN `project_id` bigint unsigned DEFAULT NULL,
+  ADD COLUMN `prompt_id` bigint unsigned DEFAULT NULL,
+  ADD COLUMN `config_id` bigint unsigned DEFAULT NULL,
+  ADD KEY `idx_tasks_created_at` (`created_at`),
+  ADD KEY `idx_tasks_project_id` (`project_id`),
+  ADD KEY `idx_tasks_prompt_id` (`prompt_id`);
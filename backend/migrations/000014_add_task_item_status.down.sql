@@ -0,0 +1,4 @@
+ALTER TABLE `task_items`
+  DROP KEY `idx_task_items_status`,
+  DROP COLUMN `status`,
+  DROP COLUMN `reason`;
@@ -0,0 +1,4 @@
+ALTER TABLE `task_items`
+  ADD COLUMN `status` varchar(16) DEFAULT NULL,
+  ADD COLUMN `reason` text,
+  ADD KEY `idx_task_items_status` (`status`);
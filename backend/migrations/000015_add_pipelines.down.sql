@@ -0,0 +1,5 @@
+ALTER TABLE `llm_test_cases`
+  DROP KEY `idx_llm_test_cases_gen_task_id`,
+  DROP COLUMN `gen_task_id`;
+
+DROP TABLE IF EXISTS `pipelines`;
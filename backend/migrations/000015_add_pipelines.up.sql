@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS `pipelines` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) DEFAULT NULL,
+  `updated_at` datetime(3) DEFAULT NULL,
+  `deleted_at` datetime(3) DEFAULT NULL,
+  `project_id` bigint unsigned DEFAULT NULL,
+  `name` longtext,
+  `description` text,
+  `prompt_ids` text,
+  `stages` text,
+  PRIMARY KEY (`id`),
+  KEY `idx_pipelines_deleted_at` (`deleted_at`),
+  KEY `idx_pipelines_project_id` (`project_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE `llm_test_cases`
+  ADD COLUMN `gen_task_id` varchar(36) DEFAULT NULL,
+  ADD KEY `idx_llm_test_cases_gen_task_id` (`gen_task_id`);
@@ -0,0 +1,7 @@
+ALTER TABLE `llm_test_cases`
+  DROP KEY `idx_llm_test_cases_experiment_id`,
+  DROP KEY `idx_llm_test_cases_eval_experiment_id`,
+  DROP COLUMN `experiment_id`,
+  DROP COLUMN `eval_experiment_id`;
+
+DROP TABLE IF EXISTS `experiments`;
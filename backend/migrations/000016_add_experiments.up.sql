@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS `experiments` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) DEFAULT NULL,
+  `updated_at` datetime(3) DEFAULT NULL,
+  `deleted_at` datetime(3) DEFAULT NULL,
+  `task_id` varchar(36) DEFAULT NULL,
+  `kind` varchar(16) DEFAULT NULL,
+  `project_id` bigint unsigned DEFAULT NULL,
+  `prompt_id` bigint unsigned DEFAULT NULL,
+  `prompt_content` text,
+  `config_id` bigint unsigned DEFAULT NULL,
+  `config` text,
+  `judge_config_id` bigint unsigned DEFAULT NULL,
+  `judge_config` text,
+  PRIMARY KEY (`id`),
+  KEY `idx_experiments_deleted_at` (`deleted_at`),
+  KEY `idx_experiments_task_id` (`task_id`),
+  KEY `idx_experiments_kind` (`kind`),
+  KEY `idx_experiments_project_id` (`project_id`),
+  KEY `idx_experiments_prompt_id` (`prompt_id`),
+  KEY `idx_experiments_config_id` (`config_id`),
+  KEY `idx_experiments_judge_config_id` (`judge_config_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE `llm_test_cases`
+  ADD COLUMN `experiment_id` bigint unsigned DEFAULT NULL,
+  ADD COLUMN `eval_experiment_id` bigint unsigned DEFAULT NULL,
+  ADD KEY `idx_llm_test_cases_experiment_id` (`experiment_id`),
+  ADD KEY `idx_llm_test_cases_eval_experiment_id` (`eval_experiment_id`);
@@ -0,0 +1,9 @@
+ALTER TABLE `llm_test_cases`
+  DROP COLUMN `critical`;
+
+ALTER TABLE `test_cases`
+  DROP COLUMN `critical`;
+
+ALTER TABLE `prompts`
+  DROP COLUMN `baseline_experiment_id`,
+  DROP COLUMN `regression_threshold`;
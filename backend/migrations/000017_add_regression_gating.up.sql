@@ -0,0 +1,9 @@
+ALTER TABLE `prompts`
+  ADD COLUMN `baseline_experiment_id` bigint unsigned DEFAULT NULL,
+  ADD COLUMN `regression_threshold` double DEFAULT NULL;
+
+ALTER TABLE `test_cases`
+  ADD COLUMN `critical` tinyint(1) DEFAULT NULL;
+
+ALTER TABLE `llm_test_cases`
+  ADD COLUMN `critical` tinyint(1) DEFAULT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE `llm_test_cases`
+  DROP COLUMN `sample`;
@@ -0,0 +1,2 @@
+ALTER TABLE `llm_test_cases`
+  ADD COLUMN `sample` bigint DEFAULT NULL;
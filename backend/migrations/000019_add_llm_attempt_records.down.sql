@@ -0,0 +1,6 @@
+ALTER TABLE `task_items`
+  DROP COLUMN `attempts`;
+
+ALTER TABLE `llm_test_cases`
+  DROP COLUMN `failed_attempts`,
+  DROP COLUMN `eval_failed_attempts`;
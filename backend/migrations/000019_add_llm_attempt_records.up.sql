@@ -0,0 +1,6 @@
+ALTER TABLE `llm_test_cases`
+  ADD COLUMN `failed_attempts` text,
+  ADD COLUMN `eval_failed_attempts` text;
+
+ALTER TABLE `task_items`
+  ADD COLUMN `attempts` text;
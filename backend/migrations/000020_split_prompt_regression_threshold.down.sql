@@ -0,0 +1,3 @@
+ALTER TABLE `prompts`
+  DROP COLUMN `min_pass_rate`,
+  RENAME COLUMN `max_pass_rate_drop` TO `regression_threshold`;
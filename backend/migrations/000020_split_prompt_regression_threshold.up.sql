@@ -0,0 +1,3 @@
+ALTER TABLE `prompts`
+  RENAME COLUMN `regression_threshold` TO `max_pass_rate_drop`,
+  ADD COLUMN `min_pass_rate` double DEFAULT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE `llm_test_cases`
+  DROP KEY `idx_llm_test_cases_source_test_case_id`,
+  DROP COLUMN `source_test_case_id`;
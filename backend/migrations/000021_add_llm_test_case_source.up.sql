@@ -0,0 +1,3 @@
+ALTER TABLE `llm_test_cases`
+  ADD COLUMN `source_test_case_id` bigint unsigned DEFAULT NULL,
+  ADD KEY `idx_llm_test_cases_source_test_case_id` (`source_test_case_id`);
@@ -0,0 +1,13 @@
+package models
+
+// Roles allowed in a conversation turn
+const (
+	ChatRoleUser      = "user"
+	ChatRoleAssistant = "assistant"
+)
+
+// ChatTurn is one prior message of a multi-turn conversation test case
+type ChatTurn struct {
+	Role    string `json:"role"` // "user" or "assistant"
+	Content string `json:"content"`
+}
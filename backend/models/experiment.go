@@ -0,0 +1,60 @@
+package models
+
+// Experiment kinds
+const (
+	ExperimentKindRun         = "run"          // Outputs produced with Config
+	ExperimentKindEvaluate    = "evaluate"     // Evaluations produced with JudgeConfig
+	ExperimentKindRunEvaluate = "run_evaluate" // Both, as done by runs from definitions
+)
+
+// ExperimentConfig is a snapshot of the LLMConfig parameters that shape a
+// call. Credentials are not copied.
+type ExperimentConfig struct {
+	ID               uint                   `json:"id"`
+	Name             string                 `json:"name"`
+	Provider         string                 `json:"provider"`
+	BaseURL          string                 `json:"base_url"`
+	ModelName        string                 `json:"model_name"`
+	Temperature      float64                `json:"temperature"`
+	MaxTokens        int                    `json:"max_tokens,omitempty"`
+	TopP             float64                `json:"top_p,omitempty"`
+	FrequencyPenalty float64                `json:"frequency_penalty,omitempty"`
+	PresencePenalty  float64                `json:"presence_penalty,omitempty"`
+	Stop             []string               `json:"stop,omitempty"`
+	Seed             *int64                 `json:"seed,omitempty"`
+	Options          map[string]interface{} `json:"options,omitempty"`
+	AzureDeployment  string                 `json:"azure_deployment,omitempty"`
+	APIVersion       string                 `json:"api_version,omitempty"`
+}
+
+// Experiment records what produced a set of LLM test case results: the
+// prompt content and the configs as they were when a task ran them. A task
+// creates one experiment per prompt it works on.
+type Experiment struct {
+	BaseModel
+	TaskID        string            `gorm:"size:36;index" json:"task_id"`
+	Kind          string            `gorm:"size:16;index" json:"kind"`
+	ProjectID     uint              `gorm:"index" json:"project_id"`
+	PromptID      uint              `gorm:"index" json:"prompt_id"`
+	PromptContent string            `gorm:"type:text" json:"prompt_content"`
+	ConfigID      uint              `gorm:"index" json:"config_id"` // Config that produced outputs, if any
+	Config        *ExperimentConfig `gorm:"type:text;serializer:json" json:"config"`
+	JudgeConfigID uint              `gorm:"index" json:"judge_config_id"` // Config that produced evaluations, if any
+	JudgeConfig   *ExperimentConfig `gorm:"type:text;serializer:json" json:"judge_config"`
+}
+
+// GateVerdict is the outcome of comparing an experiment with the baseline
+// experiment of its prompt.
+type GateVerdict struct {
+	ExperimentID         uint     `json:"experiment_id"`
+	PromptID             uint     `json:"prompt_id"`
+	BaselineExperimentID uint     `json:"baseline_experiment_id"`
+	PassRate             float64  `json:"pass_rate"`
+	BaselinePassRate     float64  `json:"baseline_pass_rate"`
+	MinPassRate          float64  `json:"min_pass_rate"`
+	MaxPassRateDrop      float64  `json:"max_pass_rate_drop"`
+	Regressions          int      `json:"regressions"`       // Inputs passing less often than in the baseline
+	CriticalFailures     []uint   `json:"critical_failures"` // Failed LLM test cases marked critical
+	Regression           bool     `json:"regression"`
+	Reasons              []string `json:"reasons,omitempty"` // Why Regression is set
+}
@@ -1,13 +1,57 @@
 package models
 
+// Supported LLM provider types
+const (
+	ProviderOpenAI    = "openai"    // OpenAI compatible /chat/completions
+	ProviderOllama    = "ollama"    // Ollama native API
+	ProviderAnthropic = "anthropic" // Anthropic Messages API
+	ProviderGemini    = "gemini"    // Google Gemini generateContent API
+	ProviderAzure     = "azure"     // Azure OpenAI deployments
+)
+
 // LLMConfig stores configuration for LLM interfaces
 type LLMConfig struct {
 	BaseModel
 	Name        string  `json:"name"`
+	Provider    string  `json:"provider" gorm:"size:32"` // Empty means detect from BaseURL
 	APIKey      string  `json:"api_key"`
 	BaseURL     string  `json:"base_url"`
 	ModelName   string  `json:"model_name"`
 	Temperature float64 `json:"temperature" gorm:"default:0.7"`
 	Tags        string  `json:"tags"` // Comma separated tags
 	IsDefault   bool    `json:"is_default" gorm:"default:false"`
+
+	// TimeoutSeconds bounds a blocking call, or the silence between chunks of
+	// a streamed one. Zero means 60 seconds.
+	TimeoutSeconds int `json:"timeout_seconds"`
+
+	// Retry policy for failed calls. Zero values use the defaults: 3 attempts,
+	// 1 second base delay doubled on every retry, no jitter, and status codes
+	// 429,500,502,503,504
+	MaxAttempts      int     `json:"max_attempts"`
+	RetryBaseDelayMs int     `json:"retry_base_delay_ms"`
+	RetryJitter      float64 `json:"retry_jitter"`                      // Fraction of the delay randomized, 0 to 1
+	RetryStatusCodes string  `json:"retry_status_codes" gorm:"size:64"` // Comma separated
+
+	// Client side limits shared by all calls made with this config, zero
+	// means unlimited
+	RequestsPerMinute int `json:"requests_per_minute"`
+	TokensPerMinute   int `json:"tokens_per_minute"`
+	MaxConcurrency    int `json:"max_concurrency"`
+
+	// Generation parameters, zero values are not sent to the provider
+	MaxTokens        int      `json:"max_tokens"`
+	TopP             float64  `json:"top_p"`
+	FrequencyPenalty float64  `json:"frequency_penalty"`
+	PresencePenalty  float64  `json:"presence_penalty"`
+	Stop             []string `json:"stop" gorm:"type:text;serializer:json"`
+	Seed             *int64   `json:"seed"` // nil means random
+
+	// Provider specific extra parameters merged into the request, e.g. Ollama
+	// num_ctx or OpenAI response_format
+	Options map[string]interface{} `json:"options" gorm:"type:text;serializer:json"`
+
+	// Azure OpenAI only
+	AzureDeployment string `json:"azure_deployment"`           // Deployment name, defaults to ModelName
+	APIVersion      string `json:"api_version" gorm:"size:32"` // api-version query parameter
 }
@@ -1,11 +1,53 @@
 package models
 
+// LLMAttempt records a failed attempt of an LLM call
+type LLMAttempt struct {
+	Attempt    int    `json:"attempt"`
+	StatusCode int    `json:"status_code,omitempty"` // HTTP status of a provider error
+	Error      string `json:"error"`
+	DelayMs    int64  `json:"delay_ms,omitempty"` // Backoff before the next attempt, zero if none followed
+}
+
 // LLMTestCase stores generated test cases
 type LLMTestCase struct {
 	BaseModel
-	PromptID   uint   `json:"prompt_id"`
-	Input      string `gorm:"type:text" json:"input"`
-	Output     string `gorm:"type:text" json:"output"`
-	Evaluation string `gorm:"type:text" json:"evaluation"` // JSON or text evaluation result
-	IsPass     bool   `json:"is_pass"`
+	PromptID     uint       `json:"prompt_id"`
+	Input        string     `gorm:"type:text" json:"input"`
+	Conversation []ChatTurn `gorm:"type:text;serializer:json" json:"conversation"` // Prior turns, Input is the turn under test
+	Output       string     `gorm:"type:text" json:"output"`
+	Evaluation   string     `gorm:"type:text" json:"evaluation"` // JSON or text evaluation result
+	IsPass       bool       `json:"is_pass"`
+	Critical     bool       `json:"critical"`
+	GenTaskID    string     `gorm:"size:36;index" json:"gen_task_id"`  // Task that generated the case, if any
+	TaskID       string     `gorm:"size:36;index" json:"task_id"`      // Task that produced Output
+	EvalTaskID   string     `gorm:"size:36;index" json:"eval_task_id"` // Task that produced Evaluation
+
+	// Experiments that produced Output and Evaluation
+	ExperimentID     uint `gorm:"index" json:"experiment_id"`
+	EvalExperimentID uint `gorm:"index" json:"eval_experiment_id"`
+
+	// Index of the repetition among the samples a run took of the same input
+	Sample int `json:"sample"`
+
+	// Case a result was saved apart from, because an experiment already owned
+	// the case or the result is a further sample of it. Zero for the cases
+	// runs take as input.
+	SourceTestCaseID uint `gorm:"index" json:"source_test_case_id"`
+
+	// Usage of the call that produced Output
+	PromptTokens     int          `json:"prompt_tokens"`
+	CompletionTokens int          `json:"completion_tokens"`
+	LatencyMs        int64        `json:"latency_ms"`
+	FinishReason     string       `gorm:"size:32" json:"finish_reason"`
+	Cost             float64      `json:"cost"`
+	Attempts         int          `json:"attempts"` // Requests made including retries
+	FailedAttempts   []LLMAttempt `gorm:"type:text;serializer:json" json:"failed_attempts,omitempty"`
+
+	// Usage of the judge call that produced Evaluation
+	EvalPromptTokens     int          `json:"eval_prompt_tokens"`
+	EvalCompletionTokens int          `json:"eval_completion_tokens"`
+	EvalLatencyMs        int64        `json:"eval_latency_ms"`
+	EvalCost             float64      `json:"eval_cost"`
+	EvalAttempts         int          `json:"eval_attempts"`
+	EvalFailedAttempts   []LLMAttempt `gorm:"type:text;serializer:json" json:"eval_failed_attempts,omitempty"`
 }
@@ -0,0 +1,12 @@
+package models
+
+// ModelPrice stores token prices for a provider/model pair. An empty Provider
+// matches the model on any provider.
+type ModelPrice struct {
+	BaseModel
+	Provider        string  `json:"provider" gorm:"size:32;index:idx_model_prices_lookup"`
+	ModelName       string  `json:"model_name" gorm:"size:191;index:idx_model_prices_lookup"`
+	InputPricePerM  float64 `json:"input_price_per_m"`  // Price per million prompt tokens
+	OutputPricePerM float64 `json:"output_price_per_m"` // Price per million completion tokens
+	Tags            string  `json:"tags"`               // Comma separated tags
+}
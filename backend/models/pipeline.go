@@ -0,0 +1,30 @@
+package models
+
+// Pipeline stage types
+const (
+	PipelineStageGenerate = "generate" // Generate LLM test cases for the prompts
+	PipelineStageRun      = "run"      // Run the test cases of the previous stage
+	PipelineStageEvaluate = "evaluate" // Evaluate the test cases of the previous stage
+)
+
+// PipelineStage is one step of a pipeline
+type PipelineStage struct {
+	Type        string `json:"type"`
+	ConfigID    uint   `json:"config_id"`
+	Count       int    `json:"count,omitempty"`       // Cases per prompt, generate only
+	Concurrency int    `json:"concurrency,omitempty"` // Workers, run and evaluate only
+	Repetitions int    `json:"repetitions,omitempty"` // Samples per test case, run only, defaults to one
+}
+
+// Pipeline stores a reusable chain of stages that runs as a single task.
+// Each stage works on the LLM test cases left by the previous one; the first
+// stage starts from the existing test cases of the prompts unless it
+// generates new ones.
+type Pipeline struct {
+	BaseModel
+	ProjectID   uint            `gorm:"index" json:"project_id"`
+	Name        string          `json:"name"`
+	Description string          `gorm:"type:text" json:"description"`
+	PromptIDs   []uint          `gorm:"type:text;serializer:json" json:"prompt_ids"` // Empty means every prompt of the project
+	Stages      []PipelineStage `gorm:"type:text;serializer:json" json:"stages"`
+}
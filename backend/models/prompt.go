@@ -7,4 +7,13 @@ type Prompt struct {
 	Name      string `json:"name"`
 	Content   string `gorm:"type:text" json:"content"`
 	Tags      string `json:"tags"` // Comma separated tags
+
+	// Regression gating: evaluated experiments of the prompt are compared
+	// with the baseline and flagged when their pass rate is below
+	// MinPassRate, is lower than the baseline's by more than MaxPassRateDrop,
+	// or a critical case fails. Both are rates from 0 to 1, a MinPassRate of
+	// 0 or a MaxPassRateDrop of 1 turns its check off.
+	BaselineExperimentID uint    `json:"baseline_experiment_id"`
+	MinPassRate          float64 `json:"min_pass_rate"`
+	MaxPassRateDrop      float64 `json:"max_pass_rate_drop"`
 }
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type TaskStatus string
+
+const (
+	TaskStatusPending     TaskStatus = "pending"
+	TaskStatusRunning     TaskStatus = "running"
+	TaskStatusCompleted   TaskStatus = "completed"
+	TaskStatusFailed      TaskStatus = "failed"
+	TaskStatusStopped     TaskStatus = "stopped"
+	TaskStatusPaused      TaskStatus = "paused"
+	TaskStatusInterrupted TaskStatus = "interrupted" // Was running when the server stopped
+)
+
+// Task types, used to rebuild the work of a task when it is resumed
+const (
+	TaskTypeRun            = "run"
+	TaskTypeEvaluate       = "evaluate"
+	TaskTypeRunDefinitions = "run_definitions"
+	TaskTypePipeline       = "pipeline"
+	TaskTypeRunMatrix      = "run_matrix"
+)
+
+// Task stores a background job and its progress
+type Task struct {
+	ID        string          `gorm:"primaryKey;size:36" json:"id"`
+	Type      string          `gorm:"size:32;index" json:"type"`
+	Status    TaskStatus      `gorm:"size:16;index" json:"status"`
+	Progress  int             `json:"progress"`
+	Total     int             `json:"total"`
+	Message   string          `json:"message"`
+	Result    interface{}     `gorm:"type:text;serializer:json" json:"result,omitempty"`
+	Error     string          `gorm:"type:text" json:"error,omitempty"`
+	Params    json.RawMessage `gorm:"type:text" json:"params"` // Parameters the task was started with
+	CreatedAt time.Time       `gorm:"index" json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+
+	// Who started the task and what it works on, zero when not applicable
+	CreatedBy string `gorm:"size:191" json:"created_by"`
+	ProjectID uint   `gorm:"index" json:"project_id"`
+	PromptID  uint   `gorm:"index" json:"prompt_id"`
+	ConfigID  uint   `json:"config_id"`
+}
+
+// Task item outcomes
+const (
+	TaskItemSucceeded = "succeeded"
+	TaskItemSkipped   = "skipped"
+	TaskItemFailed    = "failed"
+)
+
+// TaskItem stores the outcome of one item of a task, so that an interrupted
+// task can resume with the items it has not finished yet
+type TaskItem struct {
+	ID        uint         `gorm:"primaryKey" json:"id"`
+	TaskID    string       `gorm:"size:36;uniqueIndex:idx_task_items_item" json:"task_id"`
+	ItemIndex int          `gorm:"uniqueIndex:idx_task_items_item" json:"item_index"`
+	RefID     uint         `json:"ref_id"` // LLM test case the item created or updated
+	Status    string       `gorm:"size:16;index" json:"status"`
+	Reason    string       `gorm:"type:text" json:"reason,omitempty"`                   // Why a skipped item was skipped
+	Error     string       `gorm:"type:text" json:"error,omitempty"`                    // Why a failed item failed
+	Attempts  []LLMAttempt `gorm:"type:text;serializer:json" json:"attempts,omitempty"` // LLM call attempts of a failed item
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// TaskResult summarizes the outcome of the items of a task
+type TaskResult struct {
+	Succeeded int `json:"succeeded"`
+	Skipped   int `json:"skipped"`
+	Failed    int `json:"failed"`
+
+	// Verdicts of the experiments of the task against the baselines of
+	// their prompts
+	Gates []GateVerdict `json:"gates,omitempty"`
+}
@@ -13,7 +13,7 @@ func SetupRouter() *gin.Engine {
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-User")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -43,6 +43,7 @@ func SetupRouter() *gin.Engine {
 		// Prompt Routes
 		api.POST("/prompts", controllers.CreatePrompt)
 		api.POST("/prompts/generate", controllers.BatchGeneratePrompts)
+		api.POST("/prompts/:id/stream", controllers.StreamPromptRun)
 		api.GET("/prompts", controllers.GetPrompts)
 		api.PUT("/prompts/:id", controllers.UpdatePrompt)
 		api.DELETE("/prompts/batch", controllers.BatchDeletePrompts)
@@ -60,13 +61,55 @@ func SetupRouter() *gin.Engine {
 		api.POST("/llm-test-cases/generate", controllers.GenerateLLMTestCases)
 		api.POST("/llm-test-cases/run", controllers.RunLLMTestCases)
 		api.POST("/llm-test-cases/run-from-definitions", controllers.RunLLMTestCasesFromDefinitions)
+		api.POST("/llm-test-cases/run-matrix", controllers.RunLLMTestCasesMatrix)
+		api.GET("/llm-test-cases/matrix", controllers.GetMatrix)
 		api.GET("/llm-test-cases/task/status", controllers.GetTaskStatus)
 		api.POST("/llm-test-cases/task/stop", controllers.StopTask)
+		api.POST("/llm-test-cases/task/pause", controllers.PauseTask)
+		api.POST("/llm-test-cases/task/resume", controllers.ResumeTask)
+		api.POST("/llm-test-cases/task/retry-failed", controllers.RetryFailedTaskItems)
 		api.POST("/llm-test-cases/evaluate", controllers.EvaluateLLMTestCases)
 		api.GET("/llm-test-cases", controllers.GetLLMTestCases)
 		api.PUT("/llm-test-cases/:id", controllers.UpdateLLMTestCase)
 		api.DELETE("/llm-test-cases/batch", controllers.BatchDeleteLLMTestCases)
 		api.DELETE("/llm-test-cases/:id", controllers.DeleteLLMTestCase)
+
+		// Model Price Routes
+		api.POST("/model-prices", controllers.CreateModelPrice)
+		api.GET("/model-prices", controllers.GetModelPrices)
+		api.PUT("/model-prices/:id", controllers.UpdateModelPrice)
+		api.DELETE("/model-prices/batch", controllers.BatchDeleteModelPrices)
+		api.DELETE("/model-prices/:id", controllers.DeleteModelPrice)
+
+		// Pipeline Routes
+		api.POST("/pipelines", controllers.CreatePipeline)
+		api.GET("/pipelines", controllers.GetPipelines)
+		api.GET("/pipelines/:id", controllers.GetPipeline)
+		api.PUT("/pipelines/:id", controllers.UpdatePipeline)
+		api.DELETE("/pipelines/batch", controllers.BatchDeletePipelines)
+		api.DELETE("/pipelines/:id", controllers.DeletePipeline)
+		api.POST("/pipelines/:id/run", controllers.RunPipeline)
+
+		// Cost Routes
+		api.GET("/costs/summary", controllers.GetCostSummary)
+
+		// Task Routes
+		api.GET("/tasks", controllers.GetTasks)
+		api.GET("/tasks/:id", controllers.GetTask)
+		api.GET("/tasks/:id/items", controllers.GetTaskItems)
+		api.GET("/tasks/:id/events", controllers.StreamTaskEvents)
+
+		// Experiment Routes
+		api.GET("/experiments", controllers.GetExperiments)
+		api.GET("/experiments/compare", controllers.CompareExperiments)
+		api.GET("/experiments/:id", controllers.GetExperiment)
+		api.GET("/experiments/:id/results", controllers.GetExperimentResults)
+		api.GET("/experiments/:id/gate", controllers.GetGateVerdict)
+		api.GET("/experiments/:id/flakiness", controllers.GetFlakiness)
+		api.POST("/experiments/:id/baseline", controllers.PinBaseline)
+
+		// Playground Routes
+		api.POST("/playground/run", controllers.RunPlayground)
 	}
 
 	return r
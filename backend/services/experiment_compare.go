@@ -0,0 +1,280 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"codeagent-backend/utils"
+	"encoding/json"
+	"strings"
+)
+
+// maxDiffCells bounds the line pairs compared by diffLines, beyond which
+// outputs are shown as entirely replaced.
+const maxDiffCells = 1 << 22
+
+// DiffLine is a line of a line based text diff.
+type DiffLine struct {
+	Op   string `json:"op"` // "equal", "delete" (only in A) or "insert" (only in B)
+	Text string `json:"text"`
+}
+
+// ComparedResult pairs the results of the same input in two experiments.
+// A and B are the latest samples of the input, the pass rates are over all
+// its evaluated samples.
+type ComparedResult struct {
+	Input        string              `json:"input"`
+	Conversation []models.ChatTurn   `json:"conversation,omitempty"`
+	A            *models.LLMTestCase `json:"a,omitempty"`
+	B            *models.LLMTestCase `json:"b,omitempty"`
+	OutputDiff   []DiffLine          `json:"output_diff,omitempty"` // Set when the outputs of A and B differ
+
+	SamplesA  int     `json:"samples_a"`
+	SamplesB  int     `json:"samples_b"`
+	PassRateA float64 `json:"pass_rate_a"`
+	PassRateB float64 `json:"pass_rate_b"`
+}
+
+// ExperimentComparison reports how the results of experiment B differ from
+// those of experiment A.
+type ExperimentComparison struct {
+	A             *models.Experiment `json:"a"`
+	B             *models.Experiment `json:"b"`
+	PassRateA     float64            `json:"pass_rate_a"` // Over the evaluated results of A
+	PassRateB     float64            `json:"pass_rate_b"`
+	PassRateDelta float64            `json:"pass_rate_delta"` // B minus A
+
+	Regressions  []ComparedResult `json:"regressions"`  // Passes less often in B than in A
+	Improvements []ComparedResult `json:"improvements"` // Passes more often in B than in A
+	Unchanged    []ComparedResult `json:"unchanged"`    // Passes as often in both
+	Unevaluated  []ComparedResult `json:"unevaluated"`  // Missing an evaluation on either side
+	OnlyInA      []ComparedResult `json:"only_in_a"`
+	OnlyInB      []ComparedResult `json:"only_in_b"`
+}
+
+// CompareExperiments matches the results of two experiments on their input
+// and conversation, and classifies every pair by how its pass rate changed.
+// Every sample an experiment took of an input counts towards its pass rate.
+func (s *ExperimentService) CompareExperiments(idA, idB string) (*ExperimentComparison, error) {
+	a, err := s.GetExperiment(idA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.GetExperiment(idB)
+	if err != nil {
+		return nil, err
+	}
+	return compareExperiments(a, b)
+}
+
+func compareExperiments(a, b *models.Experiment) (*ExperimentComparison, error) {
+	resultsA, err := experimentResultsByInput(a.ID)
+	if err != nil {
+		return nil, err
+	}
+	resultsB, err := experimentResultsByInput(b.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &ExperimentComparison{
+		A:            a,
+		B:            b,
+		PassRateA:    resultsA.passRate(),
+		PassRateB:    resultsB.passRate(),
+		Regressions:  []ComparedResult{},
+		Improvements: []ComparedResult{},
+		Unchanged:    []ComparedResult{},
+		Unevaluated:  []ComparedResult{},
+		OnlyInA:      []ComparedResult{},
+		OnlyInB:      []ComparedResult{},
+	}
+	comparison.PassRateDelta = comparison.PassRateB - comparison.PassRateA
+
+	for _, key := range resultsA.keys {
+		samplesA := resultsA.byKey[key]
+		latestA := samplesA.latest()
+		pair := ComparedResult{
+			Input:        latestA.Input,
+			Conversation: latestA.Conversation,
+			A:            latestA,
+			SamplesA:     len(samplesA.results),
+			PassRateA:    samplesA.passRate(),
+		}
+
+		samplesB, ok := resultsB.byKey[key]
+		if !ok {
+			comparison.OnlyInA = append(comparison.OnlyInA, pair)
+			continue
+		}
+		latestB := samplesB.latest()
+		pair.B = latestB
+		pair.SamplesB = len(samplesB.results)
+		pair.PassRateB = samplesB.passRate()
+		if latestA.Output != latestB.Output {
+			pair.OutputDiff = diffLines(latestA.Output, latestB.Output)
+		}
+
+		switch {
+		case samplesA.evaluated == 0 || samplesB.evaluated == 0:
+			comparison.Unevaluated = append(comparison.Unevaluated, pair)
+		case pair.PassRateB < pair.PassRateA-passRateEpsilon:
+			comparison.Regressions = append(comparison.Regressions, pair)
+		case pair.PassRateB > pair.PassRateA+passRateEpsilon:
+			comparison.Improvements = append(comparison.Improvements, pair)
+		default:
+			comparison.Unchanged = append(comparison.Unchanged, pair)
+		}
+	}
+
+	for _, key := range resultsB.keys {
+		if _, ok := resultsA.byKey[key]; !ok {
+			samplesB := resultsB.byKey[key]
+			latestB := samplesB.latest()
+			comparison.OnlyInB = append(comparison.OnlyInB, ComparedResult{
+				Input:        latestB.Input,
+				Conversation: latestB.Conversation,
+				B:            latestB,
+				SamplesB:     len(samplesB.results),
+				PassRateB:    samplesB.passRate(),
+			})
+		}
+	}
+
+	return comparison, nil
+}
+
+// keyedResults are the results of an experiment grouped by input, keys in
+// the order the inputs first appear.
+type keyedResults struct {
+	keys  []string
+	byKey map[string]*inputResults
+}
+
+// inputResults are the samples an experiment took of one input, oldest
+// first.
+type inputResults struct {
+	results   []*models.LLMTestCase
+	evaluated int
+	passed    int
+}
+
+func (r *inputResults) latest() *models.LLMTestCase {
+	return r.results[len(r.results)-1]
+}
+
+// passRate is the share of the evaluated samples that passed.
+func (r *inputResults) passRate() float64 {
+	if r.evaluated == 0 {
+		return 0
+	}
+	return float64(r.passed) / float64(r.evaluated)
+}
+
+func experimentResultsByInput(id uint) (*keyedResults, error) {
+	var testCases []models.LLMTestCase
+	if err := utils.DB.Where("experiment_id = ? OR eval_experiment_id = ?", id, id).Order("id").Find(&testCases).Error; err != nil {
+		return nil, err
+	}
+
+	results := &keyedResults{byKey: make(map[string]*inputResults)}
+	for i := range testCases {
+		key := resultKey(testCases[i])
+		samples, ok := results.byKey[key]
+		if !ok {
+			samples = &inputResults{}
+			results.byKey[key] = samples
+			results.keys = append(results.keys, key)
+		}
+		samples.results = append(samples.results, &testCases[i])
+		if testCases[i].Evaluation != "" {
+			samples.evaluated++
+			if testCases[i].IsPass {
+				samples.passed++
+			}
+		}
+	}
+	return results, nil
+}
+
+// resultKey identifies the test case a result answers: its input and the
+// conversation leading to it.
+func resultKey(testCase models.LLMTestCase) string {
+	if len(testCase.Conversation) == 0 {
+		return testCase.Input
+	}
+	conversation, _ := json.Marshal(testCase.Conversation)
+	return testCase.Input + "\x00" + string(conversation)
+}
+
+// passRate is the share of evaluated results that passed, counting every
+// sample of every input.
+func (r *keyedResults) passRate() float64 {
+	evaluated, passed := 0, 0
+	for _, samples := range r.byKey {
+		evaluated += samples.evaluated
+		passed += samples.passed
+	}
+	if evaluated == 0 {
+		return 0
+	}
+	return float64(passed) / float64(evaluated)
+}
+
+// diffLines computes a line diff turning a into b from their longest common
+// subsequence of lines.
+func diffLines(a, b string) []DiffLine {
+	linesA := strings.Split(a, "\n")
+	linesB := strings.Split(b, "\n")
+	n, m := len(linesA), len(linesB)
+
+	if n*m > maxDiffCells {
+		diff := make([]DiffLine, 0, n+m)
+		for _, line := range linesA {
+			diff = append(diff, DiffLine{Op: "delete", Text: line})
+		}
+		for _, line := range linesB {
+			diff = append(diff, DiffLine{Op: "insert", Text: line})
+		}
+		return diff
+	}
+
+	// lcs[i][j] is the LCS length of linesA[i:] and linesB[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if linesA[i] == linesB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	diff := make([]DiffLine, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case linesA[i] == linesB[j]:
+			diff = append(diff, DiffLine{Op: "equal", Text: linesA[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, DiffLine{Op: "delete", Text: linesA[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{Op: "insert", Text: linesB[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		diff = append(diff, DiffLine{Op: "delete", Text: linesA[i]})
+	}
+	for ; j < m; j++ {
+		diff = append(diff, DiffLine{Op: "insert", Text: linesB[j]})
+	}
+	return diff
+}
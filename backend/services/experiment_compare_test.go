@@ -0,0 +1,80 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []DiffLine
+	}{
+		{
+			name: "equal",
+			a:    "one\ntwo",
+			b:    "one\ntwo",
+			want: []DiffLine{{Op: "equal", Text: "one"}, {Op: "equal", Text: "two"}},
+		},
+		{
+			name: "insert",
+			a:    "one\nthree",
+			b:    "one\ntwo\nthree",
+			want: []DiffLine{{Op: "equal", Text: "one"}, {Op: "insert", Text: "two"}, {Op: "equal", Text: "three"}},
+		},
+		{
+			name: "delete",
+			a:    "one\ntwo\nthree",
+			b:    "one\nthree",
+			want: []DiffLine{{Op: "equal", Text: "one"}, {Op: "delete", Text: "two"}, {Op: "equal", Text: "three"}},
+		},
+		{
+			name: "replace",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			want: []DiffLine{{Op: "equal", Text: "one"}, {Op: "delete", Text: "two"}, {Op: "insert", Text: "2"}, {Op: "equal", Text: "three"}},
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "one",
+			want: []DiffLine{{Op: "delete", Text: ""}, {Op: "insert", Text: "one"}},
+		},
+		{
+			name: "trailing lines",
+			a:    "one",
+			b:    "one\ntwo\nthree",
+			want: []DiffLine{{Op: "equal", Text: "one"}, {Op: "insert", Text: "two"}, {Op: "insert", Text: "three"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLines(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffLines(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesTooLarge(t *testing.T) {
+	// Past maxDiffCells the outputs are shown as entirely replaced
+	lines := 2049
+	a := strings.Repeat("a\n", lines-1) + "a"
+	b := strings.Repeat("a\n", lines-1) + "b"
+
+	diff := diffLines(a, b)
+	if len(diff) != 2*lines {
+		t.Fatalf("len(diff) = %d, want %d", len(diff), 2*lines)
+	}
+	for i, line := range diff {
+		want := "delete"
+		if i >= lines {
+			want = "insert"
+		}
+		if line.Op != want {
+			t.Fatalf("diff[%d].Op = %q, want %q", i, line.Op, want)
+		}
+	}
+}
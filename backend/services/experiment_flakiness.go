@@ -0,0 +1,134 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"codeagent-backend/utils"
+	"sort"
+)
+
+// CaseSamples summarizes the evaluated samples of one input of an
+// experiment.
+type CaseSamples struct {
+	Input        string            `json:"input"`
+	Conversation []models.ChatTurn `json:"conversation,omitempty"`
+	Samples      int               `json:"samples"`
+	Passed       int               `json:"passed"`
+	PassAtK      float64           `json:"pass_at_k"`   // Chance that at least one of k samples passes
+	PassAllK     float64           `json:"pass_all_k"`  // Chance that all of k samples pass
+	Consistency  float64           `json:"consistency"` // Share of samples agreeing with the majority verdict, 0.5 to 1
+	Flaky        bool              `json:"flaky"`       // Both passed and failed
+	ResultIDs    []uint            `json:"result_ids"`  // The LLM test cases holding the samples
+}
+
+// FlakinessReport measures how stable the verdicts of an experiment are
+// across repeated samples of the same input. Rates are averaged over cases.
+type FlakinessReport struct {
+	ExperimentID uint          `json:"experiment_id"`
+	K            int           `json:"k"`
+	Cases        int           `json:"cases"`
+	FlakyCases   int           `json:"flaky_cases"`
+	PassAtK      float64       `json:"pass_at_k"`
+	PassAllK     float64       `json:"pass_all_k"`
+	Consistency  float64       `json:"consistency"`
+	Items        []CaseSamples `json:"items"` // Least consistent first
+}
+
+// GetFlakiness groups the evaluated results of an experiment by input and
+// estimates pass@k and pass-all-k of every input from its samples. A k of
+// zero uses the largest sample count; inputs with fewer than k samples are
+// estimated with k equal to their sample count.
+func (s *ExperimentService) GetFlakiness(id uint, k int) (*FlakinessReport, error) {
+	var testCases []models.LLMTestCase
+	err := utils.DB.Where("(experiment_id = ? OR eval_experiment_id = ?) AND evaluation <> ''", id, id).
+		Order("id").
+		Find(&testCases).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	cases := make(map[string]*CaseSamples)
+	maxSamples := 0
+	for _, testCase := range testCases {
+		key := resultKey(testCase)
+		c, ok := cases[key]
+		if !ok {
+			c = &CaseSamples{Input: testCase.Input, Conversation: testCase.Conversation}
+			cases[key] = c
+			keys = append(keys, key)
+		}
+		c.Samples++
+		if testCase.IsPass {
+			c.Passed++
+		}
+		c.ResultIDs = append(c.ResultIDs, testCase.ID)
+		if c.Samples > maxSamples {
+			maxSamples = c.Samples
+		}
+	}
+
+	if k <= 0 {
+		k = maxSamples
+	}
+	report := &FlakinessReport{
+		ExperimentID: id,
+		K:            k,
+		Cases:        len(keys),
+		Items:        make([]CaseSamples, 0, len(keys)),
+	}
+	for _, key := range keys {
+		c := cases[key]
+		caseK := k
+		if caseK > c.Samples {
+			caseK = c.Samples
+		}
+		c.PassAtK = passAtK(c.Samples, c.Passed, caseK)
+		c.PassAllK = passAllK(c.Samples, c.Passed, caseK)
+		c.Consistency = float64(max(c.Passed, c.Samples-c.Passed)) / float64(c.Samples)
+		c.Flaky = c.Passed > 0 && c.Passed < c.Samples
+
+		report.PassAtK += c.PassAtK
+		report.PassAllK += c.PassAllK
+		report.Consistency += c.Consistency
+		if c.Flaky {
+			report.FlakyCases++
+		}
+		report.Items = append(report.Items, *c)
+	}
+	if report.Cases > 0 {
+		report.PassAtK /= float64(report.Cases)
+		report.PassAllK /= float64(report.Cases)
+		report.Consistency /= float64(report.Cases)
+	}
+
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		return report.Items[i].Consistency < report.Items[j].Consistency
+	})
+	return report, nil
+}
+
+// passAtK is the unbiased estimate of the chance that at least one of k
+// samples drawn from n, of which c passed, passes: 1 - C(n-c, k) / C(n, k).
+func passAtK(n, c, k int) float64 {
+	if n-c < k {
+		return 1
+	}
+	fail := 1.0
+	for i := n - c + 1; i <= n; i++ {
+		fail *= 1 - float64(k)/float64(i)
+	}
+	return 1 - fail
+}
+
+// passAllK is the chance that all of k samples drawn from n, of which c
+// passed, pass: C(c, k) / C(n, k).
+func passAllK(n, c, k int) float64 {
+	if c < k {
+		return 0
+	}
+	all := 1.0
+	for i := 0; i < k; i++ {
+		all *= float64(c-i) / float64(n-i)
+	}
+	return all
+}
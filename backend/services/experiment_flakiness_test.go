@@ -0,0 +1,45 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPassAtK(t *testing.T) {
+	tests := []struct {
+		n, c, k int
+		want    float64
+	}{
+		{n: 5, c: 5, k: 1, want: 1},
+		{n: 5, c: 0, k: 1, want: 0},
+		{n: 5, c: 0, k: 5, want: 0},
+		{n: 4, c: 2, k: 1, want: 0.5},
+		{n: 4, c: 2, k: 2, want: 5.0 / 6},   // 1 - C(2,2)/C(4,2)
+		{n: 4, c: 3, k: 2, want: 1},         // Fewer failures than draws
+		{n: 10, c: 1, k: 3, want: 3.0 / 10}, // 1 - C(9,3)/C(10,3)
+	}
+	for _, tt := range tests {
+		if got := passAtK(tt.n, tt.c, tt.k); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("passAtK(%d, %d, %d) = %v, want %v", tt.n, tt.c, tt.k, got, tt.want)
+		}
+	}
+}
+
+func TestPassAllK(t *testing.T) {
+	tests := []struct {
+		n, c, k int
+		want    float64
+	}{
+		{n: 3, c: 3, k: 3, want: 1},
+		{n: 4, c: 2, k: 1, want: 0.5},
+		{n: 4, c: 2, k: 2, want: 1.0 / 6}, // C(2,2)/C(4,2)
+		{n: 5, c: 3, k: 2, want: 3.0 / 10},
+		{n: 4, c: 1, k: 2, want: 0}, // Fewer passes than draws
+		{n: 4, c: 0, k: 1, want: 0},
+	}
+	for _, tt := range tests {
+		if got := passAllK(tt.n, tt.c, tt.k); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("passAllK(%d, %d, %d) = %v, want %v", tt.n, tt.c, tt.k, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"codeagent-backend/utils"
+	"errors"
+	"fmt"
+)
+
+var errNoBaseline = errors.New("prompt has no baseline experiment")
+
+// passRateEpsilon absorbs float rounding when comparing pass rates.
+const passRateEpsilon = 1e-9
+
+// ValidateBaseline reports an error if the gating settings of prompt are
+// invalid. The baseline must be an experiment of the prompt.
+func ValidateBaseline(prompt models.Prompt) error {
+	if prompt.MinPassRate < 0 || prompt.MinPassRate > 1 {
+		return fmt.Errorf("min_pass_rate must be between 0 and 1")
+	}
+	if prompt.MaxPassRateDrop < 0 || prompt.MaxPassRateDrop > 1 {
+		return fmt.Errorf("max_pass_rate_drop must be between 0 and 1")
+	}
+	if prompt.BaselineExperimentID == 0 {
+		return nil
+	}
+
+	var experiment models.Experiment
+	if err := utils.DB.First(&experiment, prompt.BaselineExperimentID).Error; err != nil {
+		return fmt.Errorf("baseline experiment %d not found", prompt.BaselineExperimentID)
+	}
+	if experiment.PromptID != prompt.ID {
+		return fmt.Errorf("baseline experiment %d belongs to another prompt", experiment.ID)
+	}
+	return nil
+}
+
+// PinBaseline makes an experiment the baseline of its prompt.
+func (s *ExperimentService) PinBaseline(id string) (*models.Prompt, error) {
+	experiment, err := s.GetExperiment(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var prompt models.Prompt
+	if err := utils.DB.First(&prompt, experiment.PromptID).Error; err != nil {
+		return nil, err
+	}
+	prompt.BaselineExperimentID = experiment.ID
+	if err := utils.DB.Model(&prompt).Update("baseline_experiment_id", experiment.ID).Error; err != nil {
+		return nil, err
+	}
+	return &prompt, nil
+}
+
+// GateExperiment compares an experiment with the baseline of its prompt.
+func (s *ExperimentService) GateExperiment(id string) (*models.GateVerdict, error) {
+	experiment, err := s.GetExperiment(id)
+	if err != nil {
+		return nil, err
+	}
+	return gateExperiment(experiment)
+}
+
+// gateExperiment flags experiment as a regression when its pass rate is
+// below the prompt's minimum or lower than the baseline's by more than the
+// prompt's maximum drop, or when a case marked critical failed.
+func gateExperiment(experiment *models.Experiment) (*models.GateVerdict, error) {
+	var prompt models.Prompt
+	if err := utils.DB.First(&prompt, experiment.PromptID).Error; err != nil {
+		return nil, err
+	}
+	if prompt.BaselineExperimentID == 0 {
+		return nil, errNoBaseline
+	}
+
+	var baseline models.Experiment
+	if err := utils.DB.First(&baseline, prompt.BaselineExperimentID).Error; err != nil {
+		return nil, fmt.Errorf("baseline experiment %d: %w", prompt.BaselineExperimentID, err)
+	}
+
+	comparison, err := compareExperiments(&baseline, experiment)
+	if err != nil {
+		return nil, err
+	}
+
+	verdict := &models.GateVerdict{
+		ExperimentID:         experiment.ID,
+		PromptID:             prompt.ID,
+		BaselineExperimentID: baseline.ID,
+		PassRate:             comparison.PassRateB,
+		BaselinePassRate:     comparison.PassRateA,
+		MinPassRate:          prompt.MinPassRate,
+		MaxPassRateDrop:      prompt.MaxPassRateDrop,
+		Regressions:          len(comparison.Regressions),
+		CriticalFailures:     []uint{},
+	}
+
+	err = utils.DB.Model(&models.LLMTestCase{}).
+		Where("(experiment_id = ? OR eval_experiment_id = ?) AND critical = ? AND is_pass = ? AND evaluation <> ''", experiment.ID, experiment.ID, true, false).
+		Order("id").
+		Pluck("id", &verdict.CriticalFailures).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if verdict.PassRate < verdict.MinPassRate-passRateEpsilon {
+		verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("pass rate %.1f%% is below the minimum of %.1f%%",
+			verdict.PassRate*100, verdict.MinPassRate*100))
+	}
+	if verdict.BaselinePassRate-verdict.PassRate > verdict.MaxPassRateDrop+passRateEpsilon {
+		verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("pass rate %.1f%% is more than %.1f points below the baseline's %.1f%%",
+			verdict.PassRate*100, verdict.MaxPassRateDrop*100, verdict.BaselinePassRate*100))
+	}
+	if len(verdict.CriticalFailures) > 0 {
+		verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("%d critical cases failed", len(verdict.CriticalFailures)))
+	}
+	verdict.Regression = len(verdict.Reasons) > 0
+	return verdict, nil
+}
+
+// taskGates gates the experiments that evaluated results in a task. Those of
+// prompts without a baseline, and baselines themselves, are left out.
+func taskGates(taskID string) ([]models.GateVerdict, error) {
+	var experiments []models.Experiment
+	err := utils.DB.Where("task_id = ? AND kind IN ?", taskID, []string{models.ExperimentKindEvaluate, models.ExperimentKindRunEvaluate}).
+		Order("id").
+		Find(&experiments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var verdicts []models.GateVerdict
+	for i := range experiments {
+		verdict, err := gateExperiment(&experiments[i])
+		if errors.Is(err, errNoBaseline) {
+			continue
+		}
+		if err != nil {
+			return verdicts, err
+		}
+		if verdict.BaselineExperimentID != verdict.ExperimentID {
+			verdicts = append(verdicts, *verdict)
+		}
+	}
+	return verdicts, nil
+}
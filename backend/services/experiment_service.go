@@ -0,0 +1,189 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"codeagent-backend/utils"
+	"errors"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ExperimentService struct{}
+
+// ExperimentFilter narrows an experiment listing, empty fields match
+// everything.
+type ExperimentFilter struct {
+	ProjectID string
+	PromptID  string
+	TaskID    string
+	Kind      string
+	ConfigID  string // Matches the config or the judge config
+}
+
+func (s *ExperimentService) GetExperiments(filter ExperimentFilter, page, pageSize int) ([]models.Experiment, int64, error) {
+	var experiments []models.Experiment
+	var total int64
+
+	query := utils.DB.Model(&models.Experiment{})
+	if filter.ProjectID != "" {
+		query = query.Where("project_id = ?", filter.ProjectID)
+	}
+	if filter.PromptID != "" {
+		query = query.Where("prompt_id = ?", filter.PromptID)
+	}
+	if filter.TaskID != "" {
+		query = query.Where("task_id = ?", filter.TaskID)
+	}
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	if filter.ConfigID != "" {
+		query = query.Where("(config_id = ? OR judge_config_id = ?)", filter.ConfigID, filter.ConfigID)
+	}
+
+	query.Count(&total)
+	err := query.Order("id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&experiments).Error
+	return experiments, total, err
+}
+
+func (s *ExperimentService) GetExperiment(id string) (*models.Experiment, error) {
+	var experiment models.Experiment
+	err := utils.DB.First(&experiment, id).Error
+	return &experiment, err
+}
+
+// ExperimentStats summarizes the results of an experiment.
+type ExperimentStats struct {
+	Results   int64   `json:"results"`   // Rows with an output or evaluation from the experiment
+	Evaluated int64   `json:"evaluated"` // Rows evaluated by the experiment
+	Passed    int64   `json:"passed"`
+	PassRate  float64 `json:"pass_rate"` // Passed over evaluated
+}
+
+// GetExperimentStats counts the results of an experiment. Results run or
+// evaluated again later belong to the later experiment.
+func (s *ExperimentService) GetExperimentStats(id uint) (*ExperimentStats, error) {
+	var stats ExperimentStats
+	err := utils.DB.Model(&models.LLMTestCase{}).
+		Where("experiment_id = ? OR eval_experiment_id = ?", id, id).
+		Select("COUNT(*) AS results, "+
+			"COALESCE(SUM(CASE WHEN eval_experiment_id = ? THEN 1 ELSE 0 END), 0) AS evaluated, "+
+			"COALESCE(SUM(CASE WHEN eval_experiment_id = ? AND is_pass THEN 1 ELSE 0 END), 0) AS passed", id, id).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	if stats.Evaluated > 0 {
+		stats.PassRate = float64(stats.Passed) / float64(stats.Evaluated)
+	}
+	return &stats, nil
+}
+
+func (s *ExperimentService) GetExperimentResults(id string, page, pageSize int) ([]models.LLMTestCase, int64, error) {
+	var testCases []models.LLMTestCase
+	var total int64
+
+	query := utils.DB.Model(&models.LLMTestCase{}).Where("experiment_id = ? OR eval_experiment_id = ?", id, id)
+	query.Count(&total)
+	err := query.Order("id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&testCases).Error
+	return testCases, total, err
+}
+
+// snapshotConfig copies the parameters of config that shape its calls.
+func snapshotConfig(config *models.LLMConfig) *models.ExperimentConfig {
+	if config == nil {
+		return nil
+	}
+	return &models.ExperimentConfig{
+		ID:               config.ID,
+		Name:             config.Name,
+		Provider:         ProviderName(*config),
+		BaseURL:          config.BaseURL,
+		ModelName:        config.ModelName,
+		Temperature:      config.Temperature,
+		MaxTokens:        config.MaxTokens,
+		TopP:             config.TopP,
+		FrequencyPenalty: config.FrequencyPenalty,
+		PresencePenalty:  config.PresencePenalty,
+		Stop:             config.Stop,
+		Seed:             config.Seed,
+		Options:          config.Options,
+		AzureDeployment:  config.AzureDeployment,
+		APIVersion:       config.APIVersion,
+	}
+}
+
+// taskExperiments hands out the experiments of a task, one per prompt. They
+// are created the first time a prompt is worked on and found again when the
+// task resumes, so a resumed task keeps running the prompt content it
+// started with.
+type taskExperiments struct {
+	taskID string
+	kind   string
+	config *models.LLMConfig // Config producing outputs, nil for evaluations only
+	judge  *models.LLMConfig // Config producing evaluations, nil for runs only
+
+	mu       sync.Mutex
+	byPrompt map[uint]*models.Experiment
+}
+
+func newTaskExperiments(taskID, kind string, config, judge *models.LLMConfig) *taskExperiments {
+	return &taskExperiments{
+		taskID:   taskID,
+		kind:     kind,
+		config:   config,
+		judge:    judge,
+		byPrompt: make(map[uint]*models.Experiment),
+	}
+}
+
+// get returns the experiment of the task for a prompt. The item is skipped
+// if the prompt no longer exists.
+func (e *taskExperiments) get(promptID uint) (*models.Experiment, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if experiment, ok := e.byPrompt[promptID]; ok {
+		return experiment, nil
+	}
+
+	var configID, judgeID uint
+	if e.config != nil {
+		configID = e.config.ID
+	}
+	if e.judge != nil {
+		judgeID = e.judge.ID
+	}
+
+	var experiment models.Experiment
+	err := utils.DB.Where("task_id = ? AND kind = ? AND prompt_id = ? AND config_id = ? AND judge_config_id = ?", e.taskID, e.kind, promptID, configID, judgeID).
+		First(&experiment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var prompt models.Prompt
+		if err := utils.DB.First(&prompt, promptID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, skipItem("prompt not found")
+			}
+			return nil, err
+		}
+		experiment = models.Experiment{
+			TaskID:        e.taskID,
+			Kind:          e.kind,
+			ProjectID:     prompt.ProjectID,
+			PromptID:      prompt.ID,
+			PromptContent: prompt.Content,
+			ConfigID:      configID,
+			Config:        snapshotConfig(e.config),
+			JudgeConfigID: judgeID,
+			JudgeConfig:   snapshotConfig(e.judge),
+		}
+		err = utils.DB.Create(&experiment).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e.byPrompt[promptID] = &experiment
+	return &experiment, nil
+}
@@ -48,11 +48,29 @@ func (s *LLMConfigService) UpdateLLMConfig(config *models.LLMConfig, input model
 
 	// Update fields
 	config.Name = input.Name
+	config.Provider = input.Provider
 	config.APIKey = input.APIKey
 	config.BaseURL = input.BaseURL
 	config.ModelName = input.ModelName
+	config.AzureDeployment = input.AzureDeployment
+	config.APIVersion = input.APIVersion
 	config.Temperature = input.Temperature
 	config.Tags = input.Tags
+	config.TimeoutSeconds = input.TimeoutSeconds
+	config.MaxAttempts = input.MaxAttempts
+	config.RetryBaseDelayMs = input.RetryBaseDelayMs
+	config.RetryJitter = input.RetryJitter
+	config.RetryStatusCodes = input.RetryStatusCodes
+	config.RequestsPerMinute = input.RequestsPerMinute
+	config.TokensPerMinute = input.TokensPerMinute
+	config.MaxConcurrency = input.MaxConcurrency
+	config.MaxTokens = input.MaxTokens
+	config.TopP = input.TopP
+	config.FrequencyPenalty = input.FrequencyPenalty
+	config.PresencePenalty = input.PresencePenalty
+	config.Stop = input.Stop
+	config.Seed = input.Seed
+	config.Options = input.Options
 
 	// If setting to default, unset others
 	if input.IsDefault {
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"context"
+	"sync"
+	"time"
+)
+
+// Limits are enforced per config ID and shared by every caller of
+// LLMService in the process, so concurrent tasks using the same API key
+// stay within its quota together.
+var (
+	limitersMu sync.Mutex
+	limiters   = map[uint]*configLimiter{}
+)
+
+// configLimiter enforces the request, token and concurrency limits of one
+// config.
+type configLimiter struct {
+	rpm, tpm, maxConcurrency int
+
+	requests *rateBucket // nil when unlimited
+	tokens   *rateBucket
+	slots    chan struct{}
+}
+
+// limiterFor returns the shared limiter for a config, or nil if it has no
+// limits. The limiter is rebuilt when the config's limits change.
+func limiterFor(config models.LLMConfig) *configLimiter {
+	if config.ID == 0 || (config.RequestsPerMinute <= 0 && config.TokensPerMinute <= 0 && config.MaxConcurrency <= 0) {
+		return nil
+	}
+
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	l, ok := limiters[config.ID]
+	if ok && l.rpm == config.RequestsPerMinute && l.tpm == config.TokensPerMinute && l.maxConcurrency == config.MaxConcurrency {
+		return l
+	}
+
+	l = &configLimiter{
+		rpm:            config.RequestsPerMinute,
+		tpm:            config.TokensPerMinute,
+		maxConcurrency: config.MaxConcurrency,
+	}
+	if l.rpm > 0 {
+		l.requests = newRateBucket(l.rpm)
+	}
+	if l.tpm > 0 {
+		l.tokens = newRateBucket(l.tpm)
+	}
+	if l.maxConcurrency > 0 {
+		l.slots = make(chan struct{}, l.maxConcurrency)
+	}
+	limiters[config.ID] = l
+	return l
+}
+
+// acquireLLMSlot waits until a request with config may be sent. The returned
+// release function must be called with the usage reported for the request
+// once it is done, so the token budget can be corrected from the estimate.
+func acquireLLMSlot(ctx context.Context, config models.LLMConfig, messages []ChatMessage) (func(usage TokenUsage), error) {
+	l := limiterFor(config)
+	if l == nil {
+		return func(TokenUsage) {}, nil
+	}
+
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	releaseSlot := func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+
+	if l.requests != nil {
+		if err := l.requests.wait(ctx, 1); err != nil {
+			releaseSlot()
+			return nil, err
+		}
+	}
+
+	estimate := estimateTokens(config, messages)
+	if l.tokens != nil {
+		if err := l.tokens.wait(ctx, estimate); err != nil {
+			releaseSlot()
+			return nil, err
+		}
+	}
+
+	return func(usage TokenUsage) {
+		if l.tokens != nil {
+			used := usage.TotalTokens
+			if used == 0 {
+				used = usage.PromptTokens + usage.CompletionTokens
+			}
+			if used > 0 {
+				l.tokens.adjust(estimate - used)
+			}
+		}
+		releaseSlot()
+	}, nil
+}
+
+// estimateTokens guesses the tokens a request will use before it is sent:
+// roughly four characters per prompt token plus the completion budget.
+func estimateTokens(config models.LLMConfig, messages []ChatMessage) int {
+	chars := 0
+	for _, msg := range messages {
+		chars += len(msg.Content)
+	}
+	return chars/4 + config.MaxTokens + 1
+}
+
+// rateBucket is a token bucket refilled continuously at perMinute units per
+// minute, holding at most one minute's worth.
+type rateBucket struct {
+	mu        sync.Mutex
+	capacity  float64
+	available float64
+	last      time.Time
+}
+
+func newRateBucket(perMinute int) *rateBucket {
+	return &rateBucket{
+		capacity:  float64(perMinute),
+		available: float64(perMinute),
+		last:      time.Now(),
+	}
+}
+
+func (b *rateBucket) refill(now time.Time) {
+	b.available += now.Sub(b.last).Minutes() * b.capacity
+	if b.available > b.capacity {
+		b.available = b.capacity
+	}
+	b.last = now
+}
+
+// wait blocks until n units are available and takes them. Requests larger
+// than the capacity wait for a full bucket instead of forever.
+func (b *rateBucket) wait(ctx context.Context, n int) error {
+	need := float64(n)
+	if need > b.capacity {
+		need = b.capacity
+	}
+
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.refill(now)
+		if b.available >= need {
+			b.available -= float64(n)
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((need - b.available) / b.capacity * float64(time.Minute))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// adjust returns (or, when negative, takes) units after the real cost of a
+// request is known. The balance may go negative, delaying later requests.
+func (b *rateBucket) adjust(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	b.available += float64(n)
+	if b.available > b.capacity {
+		b.available = b.capacity
+	}
+}
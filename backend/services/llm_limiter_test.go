@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRateBucketRefill(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name      string
+		available float64
+		elapsed   time.Duration
+		want      float64
+	}{
+		{name: "no time passed", available: 10, elapsed: 0, want: 10},
+		{name: "half a minute", available: 0, elapsed: 30 * time.Second, want: 30},
+		{name: "from negative", available: -30, elapsed: 45 * time.Second, want: 15},
+		{name: "capped at a minute's worth", available: 50, elapsed: 2 * time.Minute, want: 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &rateBucket{capacity: 60, available: tt.available, last: start}
+			b.refill(start.Add(tt.elapsed))
+			if math.Abs(b.available-tt.want) > 1e-9 {
+				t.Errorf("available = %v, want %v", b.available, tt.want)
+			}
+			if !b.last.Equal(start.Add(tt.elapsed)) {
+				t.Errorf("last = %v, want %v", b.last, start.Add(tt.elapsed))
+			}
+		})
+	}
+}
+
+func TestRateBucketWait(t *testing.T) {
+	tests := []struct {
+		name      string
+		available float64
+		n         int
+		wantErr   bool
+		want      float64 // Available after the wait, when it succeeds
+	}{
+		{name: "takes what is available", available: 60, n: 20, want: 40},
+		{name: "larger than capacity waits for a full bucket", available: 60, n: 100, want: -40},
+		{name: "empty bucket waits", available: 0, n: 30, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &rateBucket{capacity: 60, available: tt.available, last: time.Now()}
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			err := b.wait(ctx, tt.n)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("wait(%d) succeeded, want it to block until the context is done", tt.n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("wait(%d): %v", tt.n, err)
+			}
+			// Allow for the refill during the test
+			if b.available < tt.want || b.available > tt.want+1 {
+				t.Errorf("available = %v, want %v", b.available, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateBucketAdjust(t *testing.T) {
+	tests := []struct {
+		name      string
+		available float64
+		n         int
+		want      float64
+	}{
+		{name: "returns an overestimate", available: 10, n: 5, want: 15},
+		{name: "takes an underestimate", available: 10, n: -25, want: -15},
+		{name: "capped at capacity", available: 58, n: 10, want: 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &rateBucket{capacity: 60, available: tt.available, last: time.Now()}
+			b.adjust(tt.n)
+			if b.available < tt.want || b.available > tt.want+1 {
+				t.Errorf("available = %v, want %v", b.available, tt.want)
+			}
+		})
+	}
+}
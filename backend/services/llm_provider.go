@@ -0,0 +1,226 @@
+package services
+
+import (
+	"bytes"
+	"codeagent-backend/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider sends a chat conversation to a specific LLM backend and returns the
+// assistant's reply with the token usage reported by the backend. baseURL has
+// already been normalized by CallLLM.
+type Provider interface {
+	Chat(ctx context.Context, config models.LLMConfig, baseURL string, messages []ChatMessage) (*LLMResponse, error)
+}
+
+// StreamingProvider is implemented by providers that can deliver the reply
+// incrementally. onDelta is called for every chunk of content; returning an
+// error from it aborts the stream. The full reply is returned at the end.
+type StreamingProvider interface {
+	ChatStream(ctx context.Context, config models.LLMConfig, baseURL string, messages []ChatMessage, onDelta func(string) error) (*LLMResponse, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Provider{
+		models.ProviderOpenAI:    &OpenAIProvider{},
+		models.ProviderOllama:    &OllamaProvider{},
+		models.ProviderAnthropic: &AnthropicProvider{},
+		models.ProviderGemini:    &GeminiProvider{},
+		models.ProviderAzure:     &AzureOpenAIProvider{},
+	}
+)
+
+// TokenUsage is the token accounting reported by a provider.
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// LLMResponse is the result of a single LLM call.
+type LLMResponse struct {
+	Content      string        `json:"content"`
+	Usage        TokenUsage    `json:"usage"`
+	FinishReason string        `json:"finish_reason"` // As reported by the provider, e.g. "stop" or "length"
+	Latency      time.Duration `json:"-"`             // Set by LLMService, not by providers
+	Attempts     int           `json:"attempts"`      // Number of requests made including retries
+
+	// Attempts that failed before the one that succeeded
+	FailedAttempts []models.LLMAttempt `json:"failed_attempts,omitempty"`
+}
+
+// RegisterProvider makes a provider available under the given name so that
+// configs with a matching Provider field are routed to it.
+func RegisterProvider(name string, provider Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[name] = provider
+}
+
+// GetProvider resolves the provider for a config. Configs created before the
+// Provider field existed fall back to detecting Ollama by its URL.
+func GetProvider(config models.LLMConfig) (Provider, error) {
+	name := ProviderName(config)
+
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	provider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported LLM provider: %s", name)
+	}
+	return provider, nil
+}
+
+// ProviderName returns the provider a config is routed to, detecting it from
+// the base URL when the Provider field is empty.
+func ProviderName(config models.LLMConfig) string {
+	if config.Provider != "" {
+		return config.Provider
+	}
+	if strings.Contains(config.BaseURL, "/api/generate") || strings.Contains(config.BaseURL, "/api/chat") {
+		return models.ProviderOllama
+	}
+	return models.ProviderOpenAI
+}
+
+// ValidateProvider reports an error if name is set but no provider is
+// registered under it.
+func ValidateProvider(name string) error {
+	if name == "" {
+		return nil
+	}
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	if _, ok := providers[name]; !ok {
+		return fmt.Errorf("unsupported LLM provider: %s", name)
+	}
+	return nil
+}
+
+// withExtraParams overlays the config's extra options on top of a request body.
+// The body is returned unchanged when there is nothing to merge.
+func withExtraParams(body interface{}, extra map[string]interface{}) (interface{}, error) {
+	if len(extra) == 0 {
+		return body, nil
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	merged := map[string]interface{}{}
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	return merged, nil
+}
+
+// postJSON sends body as JSON to url and decodes a 200 response into out.
+// apiName is used to prefix error messages (e.g. "Ollama API").
+func postJSON(ctx context.Context, apiName string, url string, headers map[string]string, body interface{}, out interface{}) error {
+	resp, err := sendJSON(ctx, apiName, url, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// sendJSON posts body as JSON and returns the response if the status is 200.
+// The caller must close the response body.
+func sendJSON(ctx context.Context, apiName string, url string, headers map[string]string, body interface{}) (*http.Response, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		// Don't leak API keys passed in the query string through error messages
+		if urlErr, ok := err.(*neturl.Error); ok {
+			urlErr.URL = redactURL(urlErr.URL)
+		}
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{
+			APIName:    apiName,
+			StatusCode: resp.StatusCode,
+			Body:       string(bodyBytes),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	return resp, nil
+}
+
+// APIError is returned when a provider answers with a non-200 status.
+type APIError struct {
+	APIName    string
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // From the Retry-After header, zero if absent
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s request failed with status %d: %s", e.APIName, e.StatusCode, e.Body)
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an
+// HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// redactURL masks the "key" query parameter used by Gemini style APIs.
+func redactURL(raw string) string {
+	u, err := neturl.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	q := u.Query()
+	if q.Has("key") {
+		q.Set("key", "REDACTED")
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	anthropicVersion          = "2023-06-01"
+	anthropicDefaultMaxTokens = 4096
+)
+
+// AnthropicProvider talks to the Anthropic Messages API (/v1/messages).
+type AnthropicProvider struct{}
+
+type AnthropicRequest struct {
+	Model         string        `json:"model"`
+	System        string        `json:"system,omitempty"`
+	Messages      []ChatMessage `json:"messages"`
+	MaxTokens     int           `json:"max_tokens"`
+	Temperature   float64       `json:"temperature"`
+	TopP          float64       `json:"top_p,omitempty"`
+	StopSequences []string      `json:"stop_sequences,omitempty"`
+}
+
+type AnthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+func (p *AnthropicProvider) Chat(ctx context.Context, config models.LLMConfig, baseURL string, messages []ChatMessage) (*LLMResponse, error) {
+	// The Messages API takes the system prompt as a top-level field
+	var system []string
+	var chat []ChatMessage
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			system = append(system, msg.Content)
+			continue
+		}
+		chat = append(chat, msg)
+	}
+
+	// max_tokens is required by the Messages API
+	maxTokens := config.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
+
+	// The API has no seed or penalty parameters, those are ignored
+	reqBody, err := withExtraParams(AnthropicRequest{
+		Model:         config.ModelName,
+		System:        strings.Join(system, "\n\n"),
+		Messages:      chat,
+		MaxTokens:     maxTokens,
+		Temperature:   config.Temperature,
+		TopP:          config.TopP,
+		StopSequences: config.Stop,
+	}, config.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{
+		"x-api-key":         config.APIKey,
+		"anthropic-version": anthropicVersion,
+	}
+
+	var anthropicResp AnthropicResponse
+	url := fmt.Sprintf("%s/messages", baseURL)
+	if err := postJSON(ctx, "Anthropic API", url, headers, reqBody, &anthropicResp); err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	for _, block := range anthropicResp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+
+	if text.Len() == 0 {
+		return nil, fmt.Errorf("no text content in response (stop_reason: %s)", anthropicResp.StopReason)
+	}
+
+	return &LLMResponse{
+		Content:      text.String(),
+		FinishReason: anthropicResp.StopReason,
+		Usage: TokenUsage{
+			PromptTokens:     anthropicResp.Usage.InputTokens,
+			CompletionTokens: anthropicResp.Usage.OutputTokens,
+			TotalTokens:      anthropicResp.Usage.InputTokens + anthropicResp.Usage.OutputTokens,
+		},
+	}, nil
+}
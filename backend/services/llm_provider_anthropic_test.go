@@ -0,0 +1,89 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAnthropicProviderChat(t *testing.T) {
+	var gotPath string
+	var gotHeader http.Header
+	var gotBody AnthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotHeader = r.Header.Clone()
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"content": [
+				{"type": "text", "text": "Hello"},
+				{"type": "tool_use", "text": "ignored"},
+				{"type": "text", "text": " world"}
+			],
+			"stop_reason": "end_turn",
+			"usage": {"input_tokens": 12, "output_tokens": 3}
+		}`))
+	}))
+	defer server.Close()
+
+	rewrite := RewriteLocalhost
+	RewriteLocalhost = false
+	defer func() { RewriteLocalhost = rewrite }()
+
+	config := models.LLMConfig{
+		Provider:    models.ProviderAnthropic,
+		APIKey:      "test-key",
+		BaseURL:     server.URL + "/v1/",
+		ModelName:   "claude-test",
+		Temperature: 0.2,
+		MaxAttempts: 1,
+	}
+	messages := []ChatMessage{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Hi"},
+	}
+
+	resp, err := new(LLMService).CallLLMMessages(context.Background(), config, messages)
+	if err != nil {
+		t.Fatalf("CallLLMMessages: %v", err)
+	}
+
+	if gotPath != "/v1/messages" {
+		t.Errorf("path = %q, want /v1/messages", gotPath)
+	}
+	if got := gotHeader.Get("x-api-key"); got != "test-key" {
+		t.Errorf("x-api-key = %q, want test-key", got)
+	}
+	if got := gotHeader.Get("anthropic-version"); got != anthropicVersion {
+		t.Errorf("anthropic-version = %q, want %s", got, anthropicVersion)
+	}
+	if gotHeader.Get("Authorization") != "" {
+		t.Errorf("unexpected Authorization header %q", gotHeader.Get("Authorization"))
+	}
+
+	if gotBody.System != "Be brief." {
+		t.Errorf("system = %q, want the system message", gotBody.System)
+	}
+	if len(gotBody.Messages) != 1 || gotBody.Messages[0].Role != "user" || gotBody.Messages[0].Content != "Hi" {
+		t.Errorf("messages = %+v, want only the user turn", gotBody.Messages)
+	}
+	if gotBody.Model != "claude-test" || gotBody.MaxTokens != anthropicDefaultMaxTokens {
+		t.Errorf("model = %q, max_tokens = %d", gotBody.Model, gotBody.MaxTokens)
+	}
+
+	if resp.Content != "Hello world" {
+		t.Errorf("content = %q, want the text blocks joined", resp.Content)
+	}
+	if resp.FinishReason != "end_turn" {
+		t.Errorf("finish reason = %q, want end_turn", resp.FinishReason)
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 3 || resp.Usage.TotalTokens != 15 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// GeminiProvider talks to the Google Gemini generateContent API.
+type GeminiProvider struct{}
+
+type GeminiPart struct {
+	Text string `json:"text"`
+}
+
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+type GeminiGenerationConfig struct {
+	Temperature      float64  `json:"temperature"`
+	MaxOutputTokens  int      `json:"maxOutputTokens,omitempty"`
+	TopP             float64  `json:"topP,omitempty"`
+	FrequencyPenalty float64  `json:"frequencyPenalty,omitempty"`
+	PresencePenalty  float64  `json:"presencePenalty,omitempty"`
+	StopSequences    []string `json:"stopSequences,omitempty"`
+	Seed             *int64   `json:"seed,omitempty"`
+}
+
+type GeminiRequest struct {
+	Contents          []GeminiContent `json:"contents"`
+	SystemInstruction *GeminiContent  `json:"systemInstruction,omitempty"`
+	// GeminiGenerationConfig merged with the config's extra options
+	GenerationConfig interface{} `json:"generationConfig"`
+}
+
+type GeminiResponse struct {
+	Candidates []struct {
+		Content      GeminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// geminiBlockedReasons are finish reasons meaning the answer was withheld
+// rather than completed.
+var geminiBlockedReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+	"IMAGE_SAFETY":       true,
+}
+
+func (p *GeminiProvider) Chat(ctx context.Context, config models.LLMConfig, baseURL string, messages []ChatMessage) (*LLMResponse, error) {
+	generationConfig, err := withExtraParams(GeminiGenerationConfig{
+		Temperature:      config.Temperature,
+		MaxOutputTokens:  config.MaxTokens,
+		TopP:             config.TopP,
+		FrequencyPenalty: config.FrequencyPenalty,
+		PresencePenalty:  config.PresencePenalty,
+		StopSequences:    config.Stop,
+		Seed:             config.Seed,
+	}, config.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	reqBody := GeminiRequest{
+		GenerationConfig: generationConfig,
+	}
+
+	var system []GeminiPart
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			system = append(system, GeminiPart{Text: msg.Content})
+		case "assistant":
+			reqBody.Contents = append(reqBody.Contents, GeminiContent{Role: "model", Parts: []GeminiPart{{Text: msg.Content}}})
+		default:
+			reqBody.Contents = append(reqBody.Contents, GeminiContent{Role: "user", Parts: []GeminiPart{{Text: msg.Content}}})
+		}
+	}
+	if len(system) > 0 {
+		reqBody.SystemInstruction = &GeminiContent{Parts: system}
+	}
+
+	// The API key is passed in the query string
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent?key=%s", baseURL, config.ModelName, url.QueryEscape(config.APIKey))
+
+	var geminiResp GeminiResponse
+	if err := postJSON(ctx, "Gemini API", endpoint, nil, reqBody, &geminiResp); err != nil {
+		return nil, err
+	}
+
+	if geminiResp.PromptFeedback.BlockReason != "" {
+		return nil, fmt.Errorf("prompt blocked by Gemini: %s", geminiResp.PromptFeedback.BlockReason)
+	}
+	if len(geminiResp.Candidates) == 0 {
+		return nil, fmt.Errorf("no candidates in response")
+	}
+
+	candidate := geminiResp.Candidates[0]
+	if geminiBlockedReasons[candidate.FinishReason] {
+		return nil, fmt.Errorf("response blocked by Gemini: finish reason %s", candidate.FinishReason)
+	}
+
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		text.WriteString(part.Text)
+	}
+
+	if text.Len() == 0 {
+		return nil, fmt.Errorf("empty response from Gemini (finish reason: %s)", candidate.FinishReason)
+	}
+
+	return &LLMResponse{
+		Content:      text.String(),
+		FinishReason: candidate.FinishReason,
+		Usage: TokenUsage{
+			PromptTokens:     geminiResp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: geminiResp.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      geminiResp.UsageMetadata.TotalTokenCount,
+		},
+	}, nil
+}
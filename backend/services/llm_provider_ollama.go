@@ -0,0 +1,127 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// OllamaProvider talks to the Ollama native /api/chat endpoint. Base URLs that
+// still point at /api/generate are accepted and mapped to the same server.
+type OllamaProvider struct{}
+
+func (p *OllamaProvider) Chat(ctx context.Context, config models.LLMConfig, baseURL string, messages []ChatMessage) (*LLMResponse, error) {
+	url, headers, reqBody := p.request(config, baseURL, messages, false)
+
+	var ollamaResp OllamaResponse
+	if err := postJSON(ctx, "Ollama API", url, headers, reqBody, &ollamaResp); err != nil {
+		return nil, err
+	}
+
+	return &LLMResponse{
+		Content:      ollamaResp.Message.Content,
+		Usage:        ollamaResp.usage(),
+		FinishReason: ollamaResp.DoneReason,
+	}, nil
+}
+
+func (p *OllamaProvider) ChatStream(ctx context.Context, config models.LLMConfig, baseURL string, messages []ChatMessage, onDelta func(string) error) (*LLMResponse, error) {
+	url, headers, reqBody := p.request(config, baseURL, messages, true)
+
+	resp, err := sendJSON(ctx, "Ollama API", url, headers, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Ollama streams one JSON object per line
+	var content strings.Builder
+	result := &LLMResponse{}
+	err = readNDJSON(resp.Body, func(line []byte) error {
+		var chunk OllamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return err
+		}
+		if chunk.Error != "" {
+			return fmt.Errorf("Ollama API stream error: %s", chunk.Error)
+		}
+		// The final object carries the counters
+		if chunk.Done {
+			result.Usage = chunk.usage()
+			result.FinishReason = chunk.DoneReason
+		}
+		if chunk.Message.Content == "" {
+			return nil
+		}
+		content.WriteString(chunk.Message.Content)
+		return onDelta(chunk.Message.Content)
+	})
+	result.Content = content.String()
+	return result, err
+}
+
+// request builds the /api/chat URL, headers and body for a config.
+func (p *OllamaProvider) request(config models.LLMConfig, baseURL string, messages []ChatMessage, stream bool) (string, map[string]string, OllamaRequest) {
+	options := map[string]interface{}{
+		"temperature": config.Temperature,
+	}
+	if config.MaxTokens > 0 {
+		options["num_predict"] = config.MaxTokens
+	}
+	if config.TopP > 0 {
+		options["top_p"] = config.TopP
+	}
+	if config.FrequencyPenalty != 0 {
+		options["frequency_penalty"] = config.FrequencyPenalty
+	}
+	if config.PresencePenalty != 0 {
+		options["presence_penalty"] = config.PresencePenalty
+	}
+	if len(config.Stop) > 0 {
+		options["stop"] = config.Stop
+	}
+	if config.Seed != nil {
+		options["seed"] = *config.Seed
+	}
+	// Extra options from the config (num_ctx, mirostat, ...) win
+	for k, v := range config.Options {
+		options[k] = v
+	}
+
+	reqBody := OllamaRequest{
+		Model:    config.ModelName,
+		Messages: messages,
+		Stream:   stream,
+		Options:  options,
+	}
+
+	// Ollama usually doesn't need key, but we send it if present
+	headers := map[string]string{}
+	if config.APIKey != "" {
+		headers["Authorization"] = "Bearer " + config.APIKey
+	}
+
+	url := fmt.Sprintf("%s/api/chat", ollamaRootURL(baseURL))
+	return url, headers, reqBody
+}
+
+// usage converts Ollama's eval counters to TokenUsage.
+func (r OllamaResponse) usage() TokenUsage {
+	return TokenUsage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+// ollamaRootURL strips a trailing Ollama API path from baseURL.
+func ollamaRootURL(baseURL string) string {
+	for _, suffix := range []string{"/api/generate", "/api/chat"} {
+		if strings.HasSuffix(baseURL, suffix) {
+			return strings.TrimSuffix(baseURL, suffix)
+		}
+	}
+	return baseURL
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const azureDefaultAPIVersion = "2024-06-01"
+
+// OpenAIProvider talks to any OpenAI compatible /chat/completions endpoint.
+type OpenAIProvider struct{}
+
+func (p *OpenAIProvider) Chat(ctx context.Context, config models.LLMConfig, baseURL string, messages []ChatMessage) (*LLMResponse, error) {
+	headers := map[string]string{
+		"Authorization": "Bearer " + config.APIKey,
+	}
+	endpoint := fmt.Sprintf("%s/chat/completions", baseURL)
+	return chatCompletions(ctx, "API", endpoint, headers, config, messages)
+}
+
+func (p *OpenAIProvider) ChatStream(ctx context.Context, config models.LLMConfig, baseURL string, messages []ChatMessage, onDelta func(string) error) (*LLMResponse, error) {
+	headers := map[string]string{
+		"Authorization": "Bearer " + config.APIKey,
+	}
+	endpoint := fmt.Sprintf("%s/chat/completions", baseURL)
+	return streamChatCompletions(ctx, "API", endpoint, headers, config, messages, onDelta)
+}
+
+// AzureOpenAIProvider talks to an Azure OpenAI deployment. The base URL is the
+// resource endpoint, e.g. https://my-resource.openai.azure.com.
+type AzureOpenAIProvider struct{}
+
+func (p *AzureOpenAIProvider) Chat(ctx context.Context, config models.LLMConfig, baseURL string, messages []ChatMessage) (*LLMResponse, error) {
+	endpoint, headers := p.endpoint(config, baseURL)
+	return chatCompletions(ctx, "Azure OpenAI API", endpoint, headers, config, messages)
+}
+
+func (p *AzureOpenAIProvider) ChatStream(ctx context.Context, config models.LLMConfig, baseURL string, messages []ChatMessage, onDelta func(string) error) (*LLMResponse, error) {
+	endpoint, headers := p.endpoint(config, baseURL)
+	return streamChatCompletions(ctx, "Azure OpenAI API", endpoint, headers, config, messages, onDelta)
+}
+
+// endpoint builds the deployment URL and auth headers for a config.
+func (p *AzureOpenAIProvider) endpoint(config models.LLMConfig, baseURL string) (string, map[string]string) {
+	deployment := config.AzureDeployment
+	if deployment == "" {
+		deployment = config.ModelName
+	}
+	apiVersion := config.APIVersion
+	if apiVersion == "" {
+		apiVersion = azureDefaultAPIVersion
+	}
+
+	headers := map[string]string{
+		"api-key": config.APIKey,
+	}
+	endpoint := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		baseURL, url.PathEscape(deployment), url.QueryEscape(apiVersion))
+	return endpoint, headers
+}
+
+// chatCompletions sends an OpenAI style chat completion request.
+func chatCompletions(ctx context.Context, apiName string, endpoint string, headers map[string]string, config models.LLMConfig, messages []ChatMessage) (*LLMResponse, error) {
+	reqBody, err := chatCompletionsBody(config, messages, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var chatResp ChatResponse
+	if err := postJSON(ctx, apiName, endpoint, headers, reqBody, &chatResp); err != nil {
+		return nil, err
+	}
+
+	if len(chatResp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in response")
+	}
+
+	return &LLMResponse{
+		Content:      chatResp.Choices[0].Message.Content,
+		Usage:        chatResp.Usage,
+		FinishReason: chatResp.Choices[0].FinishReason,
+	}, nil
+}
+
+// streamChatCompletions sends an OpenAI style chat completion request with
+// stream enabled and reads the Server-Sent Events response.
+func streamChatCompletions(ctx context.Context, apiName string, endpoint string, headers map[string]string, config models.LLMConfig, messages []ChatMessage, onDelta func(string) error) (*LLMResponse, error) {
+	reqBody, err := chatCompletionsBody(config, messages, true)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := sendJSON(ctx, apiName, endpoint, headers, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	result := &LLMResponse{}
+	err = readSSE(resp.Body, func(data []byte) error {
+		var chunk ChatStreamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return err
+		}
+		// With include_usage the last chunk has usage and no choices
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			return nil
+		}
+		if chunk.Choices[0].FinishReason != "" {
+			result.FinishReason = chunk.Choices[0].FinishReason
+		}
+		delta := chunk.Choices[0].Delta.Content
+		if delta == "" {
+			return nil
+		}
+		content.WriteString(delta)
+		return onDelta(delta)
+	})
+	result.Content = content.String()
+	return result, err
+}
+
+func chatCompletionsBody(config models.LLMConfig, messages []ChatMessage, stream bool) (interface{}, error) {
+	var streamOptions *StreamOptions
+	if stream {
+		streamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	return withExtraParams(ChatRequest{
+		Model:            config.ModelName,
+		Messages:         messages,
+		Temperature:      config.Temperature,
+		MaxTokens:        config.MaxTokens,
+		TopP:             config.TopP,
+		FrequencyPenalty: config.FrequencyPenalty,
+		PresencePenalty:  config.PresencePenalty,
+		Stop:             config.Stop,
+		Seed:             config.Seed,
+		Stream:           stream,
+		StreamOptions:    streamOptions,
+	}, config.Options)
+}
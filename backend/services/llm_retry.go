@@ -0,0 +1,219 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultMaxAttempts    = 3
+	defaultRetryBaseDelay = time.Second
+	maxRetryDelay         = time.Minute
+)
+
+// defaultRetryStatusCodes are retried when a config doesn't list its own.
+var defaultRetryStatusCodes = []int{429, 500, 502, 503, 504}
+
+// RetryError is returned when a call failed on every attempt.
+type RetryError struct {
+	Attempts       int
+	Err            error
+	FailedAttempts []models.LLMAttempt // Every attempt, the last one included
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", e.Err, e.Attempts)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// AttemptsOf returns how many requests a call made, whether it succeeded or not.
+func AttemptsOf(resp *LLMResponse, err error) int {
+	if resp != nil && resp.Attempts > 0 {
+		return resp.Attempts
+	}
+	var retryErr *RetryError
+	if errors.As(err, &retryErr) {
+		return retryErr.Attempts
+	}
+	return 1
+}
+
+// FailedAttemptsOf returns the attempts of a call that failed, including the
+// final one if the call failed.
+func FailedAttemptsOf(resp *LLMResponse, err error) []models.LLMAttempt {
+	if resp != nil && len(resp.FailedAttempts) > 0 {
+		return resp.FailedAttempts
+	}
+	var retryErr *RetryError
+	if errors.As(err, &retryErr) {
+		return retryErr.FailedAttempts
+	}
+	if err != nil {
+		return []models.LLMAttempt{newLLMAttempt(1, err)}
+	}
+	return nil
+}
+
+func newLLMAttempt(attempt int, err error) models.LLMAttempt {
+	record := models.LLMAttempt{Attempt: attempt, Error: err.Error()}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		record.StatusCode = apiErr.StatusCode
+	}
+	return record
+}
+
+// retryPolicy is the resolved retry configuration of an LLMConfig.
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	jitter      float64
+	statusCodes map[int]bool
+}
+
+func newRetryPolicy(config models.LLMConfig) retryPolicy {
+	policy := retryPolicy{
+		maxAttempts: config.MaxAttempts,
+		baseDelay:   time.Duration(config.RetryBaseDelayMs) * time.Millisecond,
+		jitter:      math.Min(math.Max(config.RetryJitter, 0), 1),
+		statusCodes: map[int]bool{},
+	}
+	if policy.maxAttempts <= 0 {
+		policy.maxAttempts = defaultMaxAttempts
+	}
+	if policy.baseDelay <= 0 {
+		policy.baseDelay = defaultRetryBaseDelay
+	}
+
+	for _, code := range strings.Split(config.RetryStatusCodes, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(code)); err == nil {
+			policy.statusCodes[n] = true
+		}
+	}
+	if len(policy.statusCodes) == 0 {
+		for _, code := range defaultRetryStatusCodes {
+			policy.statusCodes[code] = true
+		}
+	}
+	return policy
+}
+
+// ValidateRetryStatusCodes reports an error if codes is not a comma separated
+// list of HTTP status codes.
+func ValidateRetryStatusCodes(codes string) error {
+	if strings.TrimSpace(codes) == "" {
+		return nil
+	}
+	for _, code := range strings.Split(codes, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(code))
+		if err != nil || n < 100 || n > 599 {
+			return fmt.Errorf("invalid retry status code: %q", strings.TrimSpace(code))
+		}
+	}
+	return nil
+}
+
+// retryable reports whether err is worth another attempt, and the delay the
+// provider asked for, if any. Provider errors are retried only for the
+// configured status codes; other 4xx responses are permanent.
+func (p retryPolicy) retryable(err error) (bool, time.Duration) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return p.statusCodes[apiErr.StatusCode], apiErr.RetryAfter
+	}
+
+	// An unknown host won't appear by retrying
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false, 0
+	}
+
+	// Transport failures: timeouts, refused or reset connections, truncated bodies
+	var netErr net.Error
+	if errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return true, 0
+	}
+	return false, 0
+}
+
+// delay is the exponential backoff before the given retry (1 for the first),
+// randomized by the jitter fraction and raised to retryAfter when larger.
+func (p retryPolicy) delay(retry int, retryAfter time.Duration) time.Duration {
+	d := float64(p.baseDelay) * math.Pow(2, float64(retry-1))
+	if p.jitter > 0 {
+		d *= 1 + p.jitter*(2*rand.Float64()-1)
+	}
+	delay := time.Duration(d)
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// withRetry runs call until it succeeds, fails permanently, runs out of
+// attempts or ctx is done. canRetry, if set, can veto retrying an attempt,
+// e.g. once part of a stream has been delivered.
+func withRetry(ctx context.Context, config models.LLMConfig, call func(ctx context.Context) (*LLMResponse, error), canRetry func() bool) (*LLMResponse, error) {
+	policy := newRetryPolicy(config)
+
+	var failed []models.LLMAttempt
+	for attempt := 1; ; attempt++ {
+		resp, err := call(ctx)
+		if err == nil {
+			resp.Attempts = attempt
+			resp.FailedAttempts = failed
+			return resp, nil
+		}
+		failed = append(failed, newLLMAttempt(attempt, err))
+		if ctx.Err() != nil {
+			if attempt > 1 {
+				err = &RetryError{Attempts: attempt, Err: err, FailedAttempts: failed}
+			}
+			return resp, err
+		}
+
+		retry, retryAfter := policy.retryable(err)
+		if !retry || attempt >= policy.maxAttempts || (canRetry != nil && !canRetry()) {
+			if attempt > 1 {
+				err = &RetryError{Attempts: attempt, Err: err, FailedAttempts: failed}
+			}
+			if resp != nil {
+				resp.Attempts = attempt
+			}
+			return resp, err
+		}
+
+		delay := policy.delay(attempt, retryAfter)
+		failed[len(failed)-1].DelayMs = delay.Milliseconds()
+		log.Printf("LLM call to %s (%s) failed on attempt %d/%d, retrying in %s: %v", config.Name, config.ModelName, attempt, policy.maxAttempts, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, &RetryError{Attempts: attempt, Err: err, FailedAttempts: failed}
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     retryPolicy
+		retry      int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{name: "first retry", policy: retryPolicy{baseDelay: time.Second}, retry: 1, min: time.Second, max: time.Second},
+		{name: "exponential", policy: retryPolicy{baseDelay: time.Second}, retry: 3, min: 4 * time.Second, max: 4 * time.Second},
+		{name: "retry after is longer", policy: retryPolicy{baseDelay: time.Second}, retry: 1, retryAfter: 10 * time.Second, min: 10 * time.Second, max: 10 * time.Second},
+		{name: "retry after is shorter", policy: retryPolicy{baseDelay: time.Second}, retry: 3, retryAfter: time.Second, min: 4 * time.Second, max: 4 * time.Second},
+		{name: "capped", policy: retryPolicy{baseDelay: time.Second}, retry: 20, min: maxRetryDelay, max: maxRetryDelay},
+		{name: "retry after capped", policy: retryPolicy{baseDelay: time.Second}, retry: 1, retryAfter: time.Hour, min: maxRetryDelay, max: maxRetryDelay},
+		{name: "jitter", policy: retryPolicy{baseDelay: time.Second, jitter: 0.5}, retry: 2, min: time.Second, max: 3 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Jitter is random, check the bounds over several draws
+			for i := 0; i < 100; i++ {
+				if got := tt.policy.delay(tt.retry, tt.retryAfter); got < tt.min || got > tt.max {
+					t.Fatalf("delay(%d, %v) = %v, want between %v and %v", tt.retry, tt.retryAfter, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		min, max time.Duration
+	}{
+		{name: "empty", value: "", min: 0, max: 0},
+		{name: "seconds", value: "3", min: 3 * time.Second, max: 3 * time.Second},
+		{name: "padded seconds", value: " 7 ", min: 7 * time.Second, max: 7 * time.Second},
+		{name: "zero", value: "0", min: 0, max: 0},
+		{name: "negative", value: "-5", min: 0, max: 0},
+		{name: "garbage", value: "soon", min: 0, max: 0},
+		{name: "past date", value: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), min: 0, max: 0},
+		{name: "future date", value: time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), min: 28 * time.Second, max: 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+				t.Errorf("parseRetryAfter(%q) = %v, want between %v and %v", tt.value, got, tt.min, tt.max)
+			}
+		})
+	}
+}
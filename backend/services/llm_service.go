@@ -1,17 +1,22 @@
 package services
 
 import (
-	"bytes"
 	"codeagent-backend/models"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// RewriteLocalhost controls whether localhost URLs are rewritten to
+// host.docker.internal before calling a provider. It is on by default because
+// the backend normally runs in Docker; turn it off to reach local test servers.
+var RewriteLocalhost = true
+
+const defaultLLMTimeout = 1 * time.Minute
+
 type LLMService struct{}
 
 type ChatMessage struct {
@@ -20,27 +25,45 @@ type ChatMessage struct {
 }
 
 type ChatRequest struct {
-	Model       string        `json:"model"`
-	Messages    []ChatMessage `json:"messages"`
-	Temperature float64       `json:"temperature"`
+	Model            string         `json:"model"`
+	Messages         []ChatMessage  `json:"messages"`
+	Temperature      float64        `json:"temperature"`
+	MaxTokens        int            `json:"max_tokens,omitempty"`
+	TopP             float64        `json:"top_p,omitempty"`
+	FrequencyPenalty float64        `json:"frequency_penalty,omitempty"`
+	PresencePenalty  float64        `json:"presence_penalty,omitempty"`
+	Stop             []string       `json:"stop,omitempty"`
+	Seed             *int64         `json:"seed,omitempty"`
+	Stream           bool           `json:"stream,omitempty"`
+	StreamOptions    *StreamOptions `json:"stream_options,omitempty"`
+}
+
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type ChatResponse struct {
 	Choices []struct {
-		Message ChatMessage `json:"message"`
+		Message      ChatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
 	} `json:"choices"`
+	Usage TokenUsage `json:"usage"`
 }
 
 type OllamaRequest struct {
-	Model   string                 `json:"model"`
-	Prompt  string                 `json:"prompt"`
-	Stream  bool                   `json:"stream"`
-	Options map[string]interface{} `json:"options,omitempty"`
+	Model    string                 `json:"model"`
+	Messages []ChatMessage          `json:"messages"`
+	Stream   bool                   `json:"stream"`
+	Options  map[string]interface{} `json:"options,omitempty"`
 }
 
 type OllamaResponse struct {
-	Response string `json:"response"`
-	Done     bool   `json:"done"`
+	Message         ChatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	Error           string      `json:"error,omitempty"`
+	DoneReason      string      `json:"done_reason"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
 }
 
 func (s *LLMService) GenerateTestCases(ctx context.Context, config models.LLMConfig, promptContent string, count int) ([]models.TestCase, error) {
@@ -58,13 +81,13 @@ Example format:
 Do not include any other text or markdown formatting.`
 	userPrompt := fmt.Sprintf("Prompt: %s\n\nGenerate %d test inputs.", promptContent, count)
 
-	response, err := s.CallLLM(ctx, config, systemPrompt, userPrompt)
+	resp, err := s.CallLLM(ctx, config, systemPrompt, userPrompt)
 	if err != nil {
 		return nil, err
 	}
 
 	// Clean up response (handle <think> tags, markdown, etc.)
-	response = s.cleanAndExtractJSON(response)
+	response := s.cleanAndExtractJSON(resp.Content)
 
 	// Parse the response (expecting JSON array)
 	var generatedInputs []struct {
@@ -103,12 +126,12 @@ Example format:
 Do not include any other text or markdown formatting.`
 	userPrompt := fmt.Sprintf("Instruction: %s\n\nGenerate %d prompts.", instruction, count)
 
-	response, err := s.CallLLM(ctx, config, systemPrompt, userPrompt)
+	resp, err := s.CallLLM(ctx, config, systemPrompt, userPrompt)
 	if err != nil {
 		return nil, err
 	}
 
-	response = s.cleanAndExtractJSON(response)
+	response := s.cleanAndExtractJSON(resp.Content)
 
 	var generatedPrompts []struct {
 		Name    string `json:"name"`
@@ -132,22 +155,50 @@ Do not include any other text or markdown formatting.`
 	return prompts, nil
 }
 
-func (s *LLMService) RunPrompt(ctx context.Context, config models.LLMConfig, promptContent string, input string) (string, error) {
-	systemPrompt := "You are a helpful assistant."
-	userPrompt := fmt.Sprintf("%s\n\nInput: %s", promptContent, input)
-	return s.CallLLM(ctx, config, systemPrompt, userPrompt)
+// RunPrompt runs a prompt on an input. When history holds prior turns of a
+// conversation, the prompt becomes the system message, the turns are replayed
+// and input is sent as the final user turn.
+func (s *LLMService) RunPrompt(ctx context.Context, config models.LLMConfig, promptContent string, history []models.ChatTurn, input string) (*LLMResponse, error) {
+	return s.CallLLMMessages(ctx, config, runPromptMessages(promptContent, history, input))
+}
+
+// StreamPrompt is RunPrompt with the output delivered incrementally to onDelta.
+func (s *LLMService) StreamPrompt(ctx context.Context, config models.LLMConfig, promptContent string, history []models.ChatTurn, input string, onDelta func(string) error) (*LLMResponse, error) {
+	return s.CallLLMStream(ctx, config, runPromptMessages(promptContent, history, input), onDelta)
+}
+
+func runPromptMessages(promptContent string, history []models.ChatTurn, input string) []ChatMessage {
+	if len(history) == 0 {
+		return []ChatMessage{
+			{Role: "system", Content: "You are a helpful assistant."},
+			{Role: "user", Content: fmt.Sprintf("%s\n\nInput: %s", promptContent, input)},
+		}
+	}
+
+	messages := []ChatMessage{{Role: "system", Content: promptContent}}
+	for _, turn := range history {
+		messages = append(messages, ChatMessage{Role: turn.Role, Content: turn.Content})
+	}
+	return append(messages, ChatMessage{Role: "user", Content: input})
 }
 
-func (s *LLMService) EvaluateTestCase(ctx context.Context, config models.LLMConfig, promptContent string, input string, output string) (string, bool, error) {
+// EvaluateTestCase asks the LLM to judge an output. For conversations the
+// judge sees the full transcript and evaluates the final assistant reply.
+// The judge call's response is returned for usage accounting.
+func (s *LLMService) EvaluateTestCase(ctx context.Context, config models.LLMConfig, promptContent string, history []models.ChatTurn, input string, output string) (string, bool, *LLMResponse, error) {
 	systemPrompt := "You are a QA engineer. Evaluate if the output matches the requirements of the prompt for the given input. Return a JSON object with 'is_pass' (boolean) and 'reason' (string). IMPORTANT: The 'reason' field MUST be written in the same language as the input text."
 	userPrompt := fmt.Sprintf("Prompt: %s\nInput: %s\nOutput: %s", promptContent, input, output)
+	if len(history) > 0 {
+		systemPrompt = "You are a QA engineer. Evaluate if the assistant's final reply in the conversation matches the requirements of the prompt, taking the whole conversation into account. Return a JSON object with 'is_pass' (boolean) and 'reason' (string). IMPORTANT: The 'reason' field MUST be written in the same language as the conversation."
+		userPrompt = fmt.Sprintf("Prompt: %s\nConversation:\n%s\nFinal Reply: %s", promptContent, formatTranscript(history, input), output)
+	}
 
-	response, err := s.CallLLM(ctx, config, systemPrompt, userPrompt)
+	resp, err := s.CallLLM(ctx, config, systemPrompt, userPrompt)
 	if err != nil {
-		return "", false, err
+		return "", false, nil, err
 	}
 
-	response = s.cleanAndExtractJSON(response)
+	response := s.cleanAndExtractJSON(resp.Content)
 
 	var result struct {
 		IsPass bool   `json:"is_pass"`
@@ -155,10 +206,10 @@ func (s *LLMService) EvaluateTestCase(ctx context.Context, config models.LLMConf
 	}
 	err = json.Unmarshal([]byte(response), &result)
 	if err != nil {
-		return response, false, nil // Failed to parse, return raw response
+		return response, false, resp, nil // Failed to parse, return raw response
 	}
 
-	return result.Reason, result.IsPass, nil
+	return result.Reason, result.IsPass, resp, nil
 }
 
 // cleanAndExtractJSON attempts to extract valid JSON from an LLM response
@@ -205,120 +256,153 @@ func (s *LLMService) cleanAndExtractJSON(response string) string {
 	return response
 }
 
-func (s *LLMService) CallLLM(ctx context.Context, config models.LLMConfig, systemContent string, userContent string) (string, error) {
-	// Add 1 minute timeout for all LLM calls
-	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
-	defer cancel()
-
-	// Handle Docker networking for localhost/127.0.0.1
-	baseURL := config.BaseURL
-	if strings.Contains(baseURL, "localhost") {
-		baseURL = strings.Replace(baseURL, "localhost", "host.docker.internal", -1)
-	}
-	if strings.Contains(baseURL, "127.0.0.1") {
-		baseURL = strings.Replace(baseURL, "127.0.0.1", "host.docker.internal", -1)
-	}
-	baseURL = strings.TrimSuffix(baseURL, "/")
-
-	// Check if this is an Ollama native API request
-	if strings.Contains(baseURL, "/api/generate") {
-		return s.callOllamaNative(ctx, config, baseURL, systemContent, userContent)
-	}
-
-	reqBody := ChatRequest{
-		Model: config.ModelName,
-		Messages: []ChatMessage{
-			{Role: "system", Content: systemContent},
-			{Role: "user", Content: userContent},
-		},
-		Temperature: config.Temperature,
+func (s *LLMService) CallLLM(ctx context.Context, config models.LLMConfig, systemContent string, userContent string) (*LLMResponse, error) {
+	messages := []ChatMessage{
+		{Role: "system", Content: systemContent},
+		{Role: "user", Content: userContent},
 	}
+	return s.CallLLMMessages(ctx, config, messages)
+}
 
-	jsonData, err := json.Marshal(reqBody)
+// CallLLMMessages sends a full conversation to the config's provider,
+// retrying transient failures according to the config's retry policy.
+// Every attempt waits for the config's rate and concurrency limits.
+// The returned response carries token usage, finish reason, latency and the
+// number of attempts made.
+func (s *LLMService) CallLLMMessages(ctx context.Context, config models.LLMConfig, messages []ChatMessage) (*LLMResponse, error) {
+	provider, err := GetProvider(config)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// OpenAI compatible URL handling
-	url := fmt.Sprintf("%s/chat/completions", baseURL)
+	baseURL := normalizeBaseURL(config.BaseURL)
+	return withRetry(ctx, config, func(ctx context.Context) (*LLMResponse, error) {
+		release, err := acquireLLMSlot(ctx, config, messages)
+		if err != nil {
+			return nil, err
+		}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", err
-	}
+		// The timeout applies to each attempt, not to the backoff between
+		// them or the wait for the rate limiter
+		ctx, cancel := context.WithTimeout(ctx, llmTimeout(config))
+		defer cancel()
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+config.APIKey)
+		start := time.Now()
+		resp, err := provider.Chat(ctx, config, baseURL, messages)
+		if err != nil {
+			release(TokenUsage{})
+			return nil, err
+		}
+		release(resp.Usage)
+		resp.Latency = time.Since(start)
+		return resp, nil
+	}, nil)
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// CallLLMStream is CallLLMMessages with the reply delivered incrementally to onDelta.
+// The config timeout applies to the gap between chunks rather than to the
+// whole call, so long outputs are not cut off. Providers without streaming
+// support deliver the full reply as a single chunk. Failed attempts are only
+// retried until the first chunk has been delivered.
+func (s *LLMService) CallLLMStream(ctx context.Context, config models.LLMConfig, messages []ChatMessage, onDelta func(string) error) (*LLMResponse, error) {
+	provider, err := GetProvider(config)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
-	}
+	baseURL := normalizeBaseURL(config.BaseURL)
 
-	var chatResp ChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return "", err
+	streaming, ok := provider.(StreamingProvider)
+	if !ok {
+		resp, err := s.CallLLMMessages(ctx, config, messages)
+		if err != nil {
+			return nil, err
+		}
+		return resp, onDelta(resp.Content)
 	}
 
-	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
-	}
+	delivered := false
+	return withRetry(ctx, config, func(ctx context.Context) (*LLMResponse, error) {
+		release, err := acquireLLMSlot(ctx, config, messages)
+		if err != nil {
+			return nil, err
+		}
 
-	return chatResp.Choices[0].Message.Content, nil
+		resp, err := streamAttempt(ctx, config, streaming, baseURL, messages, func(delta string) error {
+			delivered = true
+			return onDelta(delta)
+		})
+		if resp != nil {
+			release(resp.Usage)
+		} else {
+			release(TokenUsage{})
+		}
+		return resp, err
+	}, func() bool { return !delivered })
 }
 
-func (s *LLMService) callOllamaNative(ctx context.Context, config models.LLMConfig, url string, systemContent string, userContent string) (string, error) {
-	// Combine system and user prompt for completion API
-	fullPrompt := fmt.Sprintf("System: %s\n\nUser: %s", systemContent, userContent)
-
-	reqBody := OllamaRequest{
-		Model:  config.ModelName,
-		Prompt: fullPrompt,
-		Stream: false,
-		Options: map[string]interface{}{
-			"temperature": config.Temperature,
-		},
-	}
+// streamAttempt makes a single streaming request, cancelling it if the
+// provider stays silent for longer than the config timeout.
+func streamAttempt(ctx context.Context, config models.LLMConfig, streaming StreamingProvider, baseURL string, messages []ChatMessage, onDelta func(string) error) (*LLMResponse, error) {
+	timeout := llmTimeout(config)
+	start := time.Now()
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", err
-	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", err
+	// Cancel the request if the provider stays silent for too long
+	var idle atomic.Bool
+	timer := time.AfterFunc(timeout, func() {
+		idle.Store(true)
+		cancel()
+	})
+	defer timer.Stop()
+
+	resp, err := streaming.ChatStream(ctx, config, baseURL, messages, func(delta string) error {
+		timer.Reset(timeout)
+		return onDelta(delta)
+	})
+	if resp != nil {
+		resp.Latency = time.Since(start)
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	// Ollama usually doesn't need key, but we send it if present
-	if config.APIKey != "" {
-		req.Header.Set("Authorization", "Bearer "+config.APIKey)
+	if err != nil && idle.Load() {
+		return resp, fmt.Errorf("no data from LLM stream for %s: %w", timeout, context.DeadlineExceeded)
 	}
+	return resp, err
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
+// llmTimeout returns the configured per-call timeout, 1 minute by default.
+func llmTimeout(config models.LLMConfig) time.Duration {
+	if config.TimeoutSeconds > 0 {
+		return time.Duration(config.TimeoutSeconds) * time.Second
 	}
-	defer resp.Body.Close()
+	return defaultLLMTimeout
+}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("Ollama API request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+// formatTranscript renders prior turns plus the turn under test for the judge.
+func formatTranscript(history []models.ChatTurn, input string) string {
+	var b strings.Builder
+	for _, turn := range history {
+		role := "User"
+		if turn.Role == models.ChatRoleAssistant {
+			role = "Assistant"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", role, turn.Content)
 	}
+	fmt.Fprintf(&b, "User: %s", input)
+	return b.String()
+}
 
-	var ollamaResp OllamaResponse
-	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
-		return "", err
+// normalizeBaseURL handles Docker networking for localhost/127.0.0.1 and
+// strips the trailing slash so providers can append their paths.
+func normalizeBaseURL(baseURL string) string {
+	if RewriteLocalhost {
+		if strings.Contains(baseURL, "localhost") {
+			baseURL = strings.Replace(baseURL, "localhost", "host.docker.internal", -1)
+		}
+		if strings.Contains(baseURL, "127.0.0.1") {
+			baseURL = strings.Replace(baseURL, "127.0.0.1", "host.docker.internal", -1)
+		}
 	}
-
-	return ollamaResp.Response, nil
+	return strings.TrimSuffix(baseURL, "/")
 }
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// maxStreamLineSize bounds a single SSE/NDJSON line, large enough for long
+// chunks some providers send at the end of a stream.
+const maxStreamLineSize = 1024 * 1024
+
+// readSSE calls onData with the payload of every "data:" line of a
+// Server-Sent Events stream until the body ends or onData returns an error.
+// The OpenAI "[DONE]" sentinel ends the stream.
+func readSSE(body io.Reader, onData func(data []byte) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			continue
+		}
+		if data == "[DONE]" {
+			return nil
+		}
+		if err := onData([]byte(data)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// readNDJSON calls onLine for every non-empty line of a newline delimited
+// JSON stream, as used by Ollama.
+func readNDJSON(body io.Reader, onLine func(line []byte) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLineSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if err := onLine([]byte(line)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// ChatStreamChunk is a single chunk of an OpenAI style streamed completion.
+type ChatStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *TokenUsage `json:"usage"`
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"codeagent-backend/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// matrixTaskParams are the persisted parameters of matrix runs. Every cell,
+// a prompt and a config, runs every test case definition.
+type matrixTaskParams struct {
+	ProjectID     uint   `json:"project_id"`
+	PromptIDs     []uint `json:"prompt_ids"`
+	ConfigIDs     []uint `json:"config_ids"`
+	JudgeConfigID uint   `json:"judge_config_id,omitempty"` // Zero judges each cell with its own config
+	TestCaseIDs   []uint `json:"test_case_ids"`
+	Concurrency   int    `json:"concurrency,omitempty"`
+	Repetitions   int    `json:"repetitions,omitempty"` // Samples per definition and cell
+}
+
+// RunLLMTestCasesMatrix starts a task running the test case definitions of a
+// project for every combination of prompts and configs, repetitions times
+// each.
+func (s *LLMTestCaseService) RunLLMTestCasesMatrix(projectID uint, promptIDs, configIDs []uint, judgeConfigID uint, concurrency, repetitions int, createdBy string) (string, error) {
+	if len(promptIDs) == 0 || len(configIDs) == 0 {
+		return "", fmt.Errorf("at least one prompt and one config are required")
+	}
+	if err := ValidateRepetitions(repetitions); err != nil {
+		return "", err
+	}
+	if id, ok := duplicateID(promptIDs); ok {
+		return "", fmt.Errorf("prompt %d is listed more than once", id)
+	}
+	if id, ok := duplicateID(configIDs); ok {
+		return "", fmt.Errorf("config %d is listed more than once", id)
+	}
+
+	var promptCount int64
+	if err := utils.DB.Model(&models.Prompt{}).Where("id IN ? AND project_id = ?", promptIDs, projectID).Count(&promptCount).Error; err != nil {
+		return "", err
+	}
+	if int(promptCount) != len(promptIDs) {
+		return "", fmt.Errorf("every prompt must exist and belong to project %d", projectID)
+	}
+
+	var configCount int64
+	if err := utils.DB.Model(&models.LLMConfig{}).Where("id IN ?", configIDs).Count(&configCount).Error; err != nil {
+		return "", err
+	}
+	if int(configCount) != len(configIDs) {
+		return "", fmt.Errorf("every config must exist")
+	}
+
+	var testCaseIDs []uint
+	if err := utils.DB.Model(&models.TestCase{}).
+		Joins("JOIN prompts ON prompts.id = test_cases.prompt_id").
+		Where("prompts.project_id = ?", projectID).
+		Pluck("test_cases.id", &testCaseIDs).Error; err != nil {
+		return "", err
+	}
+	if len(testCaseIDs) == 0 {
+		return "", fmt.Errorf("no test cases found for this project")
+	}
+
+	meta := TaskMeta{CreatedBy: createdBy, ProjectID: projectID}
+	if len(promptIDs) == 1 {
+		meta.PromptID = promptIDs[0]
+	}
+	if len(configIDs) == 1 {
+		meta.ConfigID = configIDs[0]
+	}
+	params := matrixTaskParams{
+		ProjectID:     projectID,
+		PromptIDs:     promptIDs,
+		ConfigIDs:     configIDs,
+		JudgeConfigID: judgeConfigID,
+		TestCaseIDs:   testCaseIDs,
+		Concurrency:   concurrency,
+		Repetitions:   repetitions,
+	}
+	return GlobalTaskManager.StartTask(models.TaskTypeRunMatrix, meta, params, len(promptIDs)*len(configIDs)*len(testCaseIDs)*sampleCount(repetitions))
+}
+
+// duplicateID returns the first ID listed more than once in ids, if any.
+// Listing a prompt or config twice would run its cells twice.
+func duplicateID(ids []uint) (uint, bool) {
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return id, true
+		}
+		seen[id] = true
+	}
+	return 0, false
+}
+
+func (s *LLMTestCaseService) runMatrixTask(data json.RawMessage) (TaskFunc, error) {
+	var params matrixTaskParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+
+	configs := make([]models.LLMConfig, len(params.ConfigIDs))
+	workers := 0
+	for i, id := range params.ConfigIDs {
+		if err := utils.DB.First(&configs[i], id).Error; err != nil {
+			return nil, fmt.Errorf("config %d: %w", id, err)
+		}
+		workers += taskWorkers(0, configs[i])
+	}
+
+	var judge *models.LLMConfig
+	if params.JudgeConfigID != 0 {
+		judge = new(models.LLMConfig)
+		if err := utils.DB.First(judge, params.JudgeConfigID).Error; err != nil {
+			return nil, fmt.Errorf("judge config %d: %w", params.JudgeConfigID, err)
+		}
+	}
+
+	// Without a requested count, every config gets the workers it would
+	// have in a run of its own
+	workers = taskWorkers(params.Concurrency, models.LLMConfig{MaxConcurrency: workers})
+
+	return func(ctx context.Context, run *TaskRun, updateProgress func(int, string) error) error {
+		experiments := make([]*taskExperiments, len(configs))
+		for i := range configs {
+			cellJudge := judge
+			if cellJudge == nil {
+				cellJudge = &configs[i]
+			}
+			experiments[i] = newTaskExperiments(run.TaskID, models.ExperimentKindRunEvaluate, &configs[i], cellJudge)
+		}
+
+		// Consecutive items go to different cells so the workers spread over
+		// the configs instead of queueing on the limits of one of them, and
+		// every definition is sampled once before any is sampled again
+		cells := len(params.PromptIDs) * len(configs)
+		cases := len(params.TestCaseIDs)
+		total := cells * cases * sampleCount(params.Repetitions)
+		return runParallel(ctx, run, total, workers, "Running matrix", updateProgress, func(ctx context.Context, i int) (uint, error) {
+			cell, rest := i%cells, i/cells
+			testCase, sample := rest%cases, rest/cases
+			promptIndex, configIndex := cell/len(configs), cell%len(configs)
+
+			experiment, err := experiments[configIndex].get(params.PromptIDs[promptIndex])
+			if err != nil {
+				return 0, err
+			}
+			return s.runDefinition(ctx, configs[configIndex], *experiments[configIndex].judge, experiment, params.TestCaseIDs[testCase], sample)
+		})
+	}, nil
+}
+
+// MatrixCell aggregates the results of one prompt and config of a matrix run.
+type MatrixCell struct {
+	PromptID     uint    `json:"prompt_id"`
+	PromptName   string  `json:"prompt_name"`
+	ConfigID     uint    `json:"config_id"`
+	ConfigName   string  `json:"config_name"`
+	ExperimentID uint    `json:"experiment_id"` // Zero until the cell has started
+	Results      int64   `json:"results"`
+	Passed       int64   `json:"passed"`
+	PassRate     float64 `json:"pass_rate"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"` // Of the successful calls
+	Cost         float64 `json:"cost"`
+	EvalCost     float64 `json:"eval_cost"`
+}
+
+// MatrixReport lays out the cells of a matrix run, prompt by prompt in the
+// order they were submitted.
+type MatrixReport struct {
+	TaskID        string       `json:"task_id"`
+	PromptIDs     []uint       `json:"prompt_ids"`
+	ConfigIDs     []uint       `json:"config_ids"`
+	JudgeConfigID uint         `json:"judge_config_id,omitempty"`
+	Cells         []MatrixCell `json:"cells"`
+}
+
+// GetMatrix aggregates the results of a matrix run so far.
+func (s *LLMTestCaseService) GetMatrix(taskID string) (*MatrixReport, error) {
+	task, ok := GlobalTaskManager.GetTask(taskID)
+	if !ok {
+		return nil, fmt.Errorf("task not found")
+	}
+	if task.Type != models.TaskTypeRunMatrix {
+		return nil, fmt.Errorf("task is not a matrix run")
+	}
+
+	var params matrixTaskParams
+	if err := json.Unmarshal(task.Params, &params); err != nil {
+		return nil, err
+	}
+
+	var experiments []models.Experiment
+	if err := utils.DB.Where("task_id = ?", taskID).Find(&experiments).Error; err != nil {
+		return nil, err
+	}
+	type cellKey struct{ promptID, configID uint }
+	experimentIDs := make(map[cellKey]uint, len(experiments))
+	ids := make([]uint, 0, len(experiments))
+	for _, experiment := range experiments {
+		experimentIDs[cellKey{experiment.PromptID, experiment.ConfigID}] = experiment.ID
+		ids = append(ids, experiment.ID)
+	}
+
+	var rows []struct {
+		ExperimentID uint
+		Results      int64
+		Passed       int64
+		AvgLatencyMs float64
+		Cost         float64
+		EvalCost     float64
+	}
+	if len(ids) > 0 {
+		err := utils.DB.Model(&models.LLMTestCase{}).
+			Select("experiment_id, COUNT(*) AS results, "+
+				"COALESCE(SUM(CASE WHEN is_pass THEN 1 ELSE 0 END), 0) AS passed, "+
+				"COALESCE(AVG(CASE WHEN latency_ms > 0 THEN latency_ms END), 0) AS avg_latency_ms, "+
+				"COALESCE(SUM(cost), 0) AS cost, COALESCE(SUM(eval_cost), 0) AS eval_cost").
+			Where("experiment_id IN ?", ids).
+			Group("experiment_id").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	byExperiment := make(map[uint]int, len(rows))
+	for i, row := range rows {
+		byExperiment[row.ExperimentID] = i
+	}
+
+	var prompts []models.Prompt
+	utils.DB.Unscoped().Find(&prompts, params.PromptIDs)
+	promptNames := make(map[uint]string, len(prompts))
+	for _, prompt := range prompts {
+		promptNames[prompt.ID] = prompt.Name
+	}
+	var configs []models.LLMConfig
+	utils.DB.Unscoped().Find(&configs, params.ConfigIDs)
+	configNames := make(map[uint]string, len(configs))
+	for _, config := range configs {
+		configNames[config.ID] = config.Name
+	}
+
+	report := &MatrixReport{
+		TaskID:        taskID,
+		PromptIDs:     params.PromptIDs,
+		ConfigIDs:     params.ConfigIDs,
+		JudgeConfigID: params.JudgeConfigID,
+		Cells:         make([]MatrixCell, 0, len(params.PromptIDs)*len(params.ConfigIDs)),
+	}
+	for _, promptID := range params.PromptIDs {
+		for _, configID := range params.ConfigIDs {
+			cell := MatrixCell{
+				PromptID:     promptID,
+				PromptName:   promptNames[promptID],
+				ConfigID:     configID,
+				ConfigName:   configNames[configID],
+				ExperimentID: experimentIDs[cellKey{promptID, configID}],
+			}
+			if i, ok := byExperiment[cell.ExperimentID]; ok {
+				row := rows[i]
+				cell.Results = row.Results
+				cell.Passed = row.Passed
+				cell.AvgLatencyMs = row.AvgLatencyMs
+				cell.Cost = row.Cost
+				cell.EvalCost = row.EvalCost
+				if row.Results > 0 {
+					cell.PassRate = float64(row.Passed) / float64(row.Results)
+				}
+			}
+			report.Cells = append(report.Cells, cell)
+		}
+	}
+	return report, nil
+}
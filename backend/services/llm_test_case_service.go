@@ -4,7 +4,13 @@ import (
 	"codeagent-backend/models"
 	"codeagent-backend/utils"
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type LLMTestCaseService struct {
@@ -78,125 +84,561 @@ func (s *LLMTestCaseService) GenerateLLMTestCases(ctx context.Context, configID
 	return allCreatedCases, nil
 }
 
-func (s *LLMTestCaseService) RunLLMTestCases(testCaseIDs []uint, configID uint) (string, error) {
-	var config models.LLMConfig
-	if err := utils.DB.First(&config, configID).Error; err != nil {
+// testCaseTaskParams are the persisted parameters of run and evaluate tasks.
+type testCaseTaskParams struct {
+	TestCaseIDs []uint `json:"test_case_ids"`
+	ConfigID    uint   `json:"config_id"`
+	PromptID    uint   `json:"prompt_id,omitempty"` // Run from definitions only
+	Concurrency int    `json:"concurrency,omitempty"`
+	Repetitions int    `json:"repetitions,omitempty"` // Samples per definition, run from definitions only
+}
+
+// maxRepetitions bounds the samples a run can take of each input.
+const maxRepetitions = 100
+
+// ValidateRepetitions reports an error if a run can't take n samples of each
+// input. Zero means one.
+func ValidateRepetitions(n int) error {
+	if n < 0 || n > maxRepetitions {
+		return fmt.Errorf("repetitions must be between 1 and %d", maxRepetitions)
+	}
+	return nil
+}
+
+// sampleCount resolves the persisted repetitions of a task, zero for tasks
+// started before sampling existed.
+func sampleCount(repetitions int) int {
+	if repetitions < 1 {
+		return 1
+	}
+	return repetitions
+}
+
+func init() {
+	s := &LLMTestCaseService{LLMService: new(LLMService)}
+	RegisterTaskHandler(models.TaskTypeRun, s.runTask)
+	RegisterTaskHandler(models.TaskTypeEvaluate, s.evaluateTask)
+	RegisterTaskHandler(models.TaskTypeRunDefinitions, s.runDefinitionsTask)
+	RegisterTaskHandler(models.TaskTypeRunMatrix, s.runMatrixTask)
+}
+
+// generateTestCases generates count LLM test cases for a prompt, attributed
+// to the task in ctx. It is the work of an item of pipeline generate stages.
+func (s *LLMTestCaseService) generateTestCases(ctx context.Context, config models.LLMConfig, promptID uint, count int) (uint, error) {
+	var prompt models.Prompt
+	if err := utils.DB.First(&prompt, promptID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, skipItem("prompt not found")
+		}
+		return 0, err
+	}
+
+	generated, err := s.LLMService.GenerateTestCases(ctx, config, prompt.Content, count)
+	if err != nil {
+		return 0, err
+	}
+	if len(generated) == 0 {
+		return 0, fmt.Errorf("no test cases generated for prompt %d", prompt.ID)
+	}
+
+	// Leave items cut short by a stop to be generated again on resume
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	testCases := make([]models.LLMTestCase, len(generated))
+	for i, tc := range generated {
+		testCases[i] = models.LLMTestCase{
+			PromptID:     prompt.ID,
+			Input:        tc.Input,
+			Conversation: tc.Conversation,
+			GenTaskID:    TaskIDFromContext(ctx),
+		}
+	}
+	return 0, utils.DB.Create(&testCases).Error
+}
+
+// llmTestCaseTaskMeta attributes a task to the prompt and project of its test
+// cases when they all share the same one.
+func llmTestCaseTaskMeta(testCaseIDs []uint, configID uint, createdBy string) TaskMeta {
+	meta := TaskMeta{CreatedBy: createdBy, ConfigID: configID}
+
+	var promptIDs []uint
+	utils.DB.Model(&models.LLMTestCase{}).Where("id IN ?", testCaseIDs).Distinct().Pluck("prompt_id", &promptIDs)
+	if len(promptIDs) == 1 {
+		meta.PromptID = promptIDs[0]
+	}
+
+	var projectIDs []uint
+	utils.DB.Model(&models.Prompt{}).Where("id IN ?", promptIDs).Distinct().Pluck("project_id", &projectIDs)
+	if len(projectIDs) == 1 {
+		meta.ProjectID = projectIDs[0]
+	}
+	return meta
+}
+
+// RunLLMTestCases starts a task running LLM test cases, repetitions times
+// each.
+func (s *LLMTestCaseService) RunLLMTestCases(testCaseIDs []uint, configID uint, concurrency, repetitions int, createdBy string) (string, error) {
+	if err := ValidateRepetitions(repetitions); err != nil {
 		return "", err
 	}
 
-	taskID := GlobalTaskManager.StartTask(len(testCaseIDs), func(ctx context.Context, updateProgress func(int, string) error) error {
-		for i, id := range testCaseIDs {
-			if err := updateProgress(i, fmt.Sprintf("Running test case %d/%d", i+1, len(testCaseIDs))); err != nil {
-				return err
-			}
+	meta := llmTestCaseTaskMeta(testCaseIDs, configID, createdBy)
+	return GlobalTaskManager.StartTask(models.TaskTypeRun, meta, testCaseTaskParams{
+		TestCaseIDs: testCaseIDs,
+		ConfigID:    configID,
+		Concurrency: concurrency,
+		Repetitions: repetitions,
+	}, len(testCaseIDs)*sampleCount(repetitions))
+}
 
-			var testCase models.LLMTestCase
-			if err := utils.DB.First(&testCase, id).Error; err != nil {
-				continue
-			}
+func (s *LLMTestCaseService) runTask(data json.RawMessage) (TaskFunc, error) {
+	var params testCaseTaskParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
 
-			var prompt models.Prompt
-			utils.DB.First(&prompt, testCase.PromptID)
+	var config models.LLMConfig
+	if err := utils.DB.First(&config, params.ConfigID).Error; err != nil {
+		return nil, err
+	}
 
-			output, err := s.LLMService.RunPrompt(ctx, config, prompt.Content, testCase.Input)
-			if err == nil {
-				testCase.Output = output
-				utils.DB.Save(&testCase)
-			}
+	return func(ctx context.Context, run *TaskRun, updateProgress func(int, string) error) error {
+		experiments := newTaskExperiments(run.TaskID, models.ExperimentKindRun, &config, nil)
+
+		// Every case is sampled once before any is sampled again
+		cases := len(params.TestCaseIDs)
+		total := cases * sampleCount(params.Repetitions)
+		return runParallel(ctx, run, total, taskWorkers(params.Concurrency, config), "Running test cases", updateProgress, func(ctx context.Context, i int) (uint, error) {
+			return s.runTestCase(ctx, config, experiments, params.TestCaseIDs[i%cases], i/cases)
+		})
+	}, nil
+}
+
+// runTestCase runs one LLM test case with the prompt content of its
+// experiment and saves its output, numbered sample among the repetitions of
+// the case. The output of another experiment is kept, and further samples
+// are never saved to the case itself: the result is then saved as a new LLM
+// test case. It is the work of an item of run tasks and returns the ID of
+// the case holding the output.
+func (s *LLMTestCaseService) runTestCase(ctx context.Context, config models.LLMConfig, experiments *taskExperiments, id uint, sample int) (uint, error) {
+	var testCase models.LLMTestCase
+	if err := utils.DB.First(&testCase, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return id, skipItem("test case not found")
 		}
-		return nil
-	})
+		return id, err
+	}
+
+	experiment, err := experiments.get(testCase.PromptID)
+	if err != nil {
+		return testCase.ID, err
+	}
+
+	resp, err := s.LLMService.RunPrompt(ctx, config, experiment.PromptContent, testCase.Conversation, testCase.Input)
+	if err != nil {
+		return testCase.ID, err
+	}
+
+	// Leave items cut short by a stop to be run again on resume
+	if ctx.Err() != nil {
+		return testCase.ID, ctx.Err()
+	}
+
+	result := models.LLMTestCase{
+		PromptID:     testCase.PromptID,
+		Input:        testCase.Input,
+		Conversation: testCase.Conversation,
+		Critical:     testCase.Critical,
+		Output:       resp.Content,
+		Sample:       sample,
+		TaskID:       TaskIDFromContext(ctx),
+		ExperimentID: experiment.ID,
+
+		SourceTestCaseID: sourceTestCaseID(testCase),
+	}
+	recordRunUsage(&result, config, resp)
+
+	if sample > 0 {
+		if err := utils.DB.Create(&result).Error; err != nil {
+			return testCase.ID, err
+		}
+		return result.ID, nil
+	}
 
-	return taskID, nil
+	// A case no experiment has run or evaluated yet takes the output, which
+	// also clears an evaluation of a previous output
+	columns := append(append([]string{"sample"}, runResultColumns...), evalResultColumns...)
+	return saveResult(testCase.ID, &result, columns, "COALESCE(experiment_id, 0) IN ? AND COALESCE(eval_experiment_id, 0) = 0", []uint{0, experiment.ID})
 }
 
-func (s *LLMTestCaseService) EvaluateLLMTestCases(testCaseIDs []uint, configID uint) (string, error) {
+func (s *LLMTestCaseService) EvaluateLLMTestCases(testCaseIDs []uint, configID uint, concurrency int, createdBy string) (string, error) {
+	meta := llmTestCaseTaskMeta(testCaseIDs, configID, createdBy)
+	return GlobalTaskManager.StartTask(models.TaskTypeEvaluate, meta, testCaseTaskParams{
+		TestCaseIDs: testCaseIDs,
+		ConfigID:    configID,
+		Concurrency: concurrency,
+	}, len(testCaseIDs))
+}
+
+func (s *LLMTestCaseService) evaluateTask(data json.RawMessage) (TaskFunc, error) {
+	var params testCaseTaskParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+
 	var config models.LLMConfig
-	if err := utils.DB.First(&config, configID).Error; err != nil {
-		return "", err
+	if err := utils.DB.First(&config, params.ConfigID).Error; err != nil {
+		return nil, err
 	}
 
-	taskID := GlobalTaskManager.StartTask(len(testCaseIDs), func(ctx context.Context, updateProgress func(int, string) error) error {
-		for i, id := range testCaseIDs {
-			if err := updateProgress(i, fmt.Sprintf("Evaluating test case %d/%d", i+1, len(testCaseIDs))); err != nil {
-				return err
-			}
+	return func(ctx context.Context, run *TaskRun, updateProgress func(int, string) error) error {
+		experiments := newTaskExperiments(run.TaskID, models.ExperimentKindEvaluate, nil, &config)
+		return runParallel(ctx, run, len(params.TestCaseIDs), taskWorkers(params.Concurrency, config), "Evaluating test cases", updateProgress, func(ctx context.Context, i int) (uint, error) {
+			return s.evaluateTestCase(ctx, config, experiments, params.TestCaseIDs[i])
+		})
+	}, nil
+}
 
-			var testCase models.LLMTestCase
-			if err := utils.DB.First(&testCase, id).Error; err != nil {
-				continue
-			}
+// evaluateTestCase judges the output of one LLM test case with config
+// against the prompt content of its experiment. The evaluation of another
+// experiment is kept, the result is then saved as a new LLM test case. It is
+// the work of an item of evaluate tasks and returns the ID of the case
+// holding the evaluation.
+func (s *LLMTestCaseService) evaluateTestCase(ctx context.Context, config models.LLMConfig, experiments *taskExperiments, id uint) (uint, error) {
+	var testCase models.LLMTestCase
+	if err := utils.DB.First(&testCase, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return id, skipItem("test case not found")
+		}
+		return id, err
+	}
 
-			if testCase.Output == "" {
-				continue
-			}
+	if testCase.Output == "" {
+		return testCase.ID, skipItem("no output to evaluate")
+	}
 
-			var prompt models.Prompt
-			utils.DB.First(&prompt, testCase.PromptID)
+	experiment, err := experiments.get(testCase.PromptID)
+	if err != nil {
+		return testCase.ID, err
+	}
 
-			reason, isPass, err := s.LLMService.EvaluateTestCase(ctx, config, prompt.Content, testCase.Input, testCase.Output)
-			if err == nil {
-				testCase.Evaluation = reason
-				testCase.IsPass = isPass
-				utils.DB.Save(&testCase)
-			}
+	reason, isPass, evalResp, err := s.LLMService.EvaluateTestCase(ctx, config, experiment.PromptContent, testCase.Conversation, testCase.Input, testCase.Output)
+	if err != nil {
+		return testCase.ID, err
+	}
+
+	// Leave items cut short by a stop to be evaluated again on resume
+	if ctx.Err() != nil {
+		return testCase.ID, ctx.Err()
+	}
+
+	// A copy of the case carries the output without its run, so that the
+	// run's experiment doesn't count it twice
+	result := models.LLMTestCase{
+		PromptID:         testCase.PromptID,
+		Input:            testCase.Input,
+		Conversation:     testCase.Conversation,
+		Critical:         testCase.Critical,
+		Output:           testCase.Output,
+		Sample:           testCase.Sample,
+		Evaluation:       reason,
+		IsPass:           isPass,
+		EvalTaskID:       TaskIDFromContext(ctx),
+		EvalExperimentID: experiment.ID,
+
+		SourceTestCaseID: sourceTestCaseID(testCase),
+	}
+	recordEvalUsage(&result, config, evalResp)
+
+	// The case takes the evaluation unless another experiment evaluated it,
+	// or its output changed since it was judged
+	return saveResult(testCase.ID, &result, evalResultColumns, "COALESCE(eval_experiment_id, 0) IN ? AND output = ?", []uint{0, experiment.ID}, testCase.Output)
+}
+
+// sourceTestCaseID returns the input case that results of testCase are saved
+// apart from, following a result back to its source.
+func sourceTestCaseID(testCase models.LLMTestCase) uint {
+	if testCase.SourceTestCaseID != 0 {
+		return testCase.SourceTestCaseID
+	}
+	return testCase.ID
+}
+
+// Columns of an LLM test case holding the result of a run, and of an
+// evaluation
+var (
+	runResultColumns  = []string{"output", "task_id", "experiment_id", "prompt_tokens", "completion_tokens", "latency_ms", "finish_reason", "cost", "attempts", "failed_attempts"}
+	evalResultColumns = []string{"evaluation", "is_pass", "eval_task_id", "eval_experiment_id", "eval_prompt_tokens", "eval_completion_tokens", "eval_latency_ms", "eval_cost", "eval_attempts", "eval_failed_attempts"}
+)
+
+// saveResult saves the result of a run or evaluation of the LLM test case
+// id. The columns of result are written to the case itself if it matches
+// the query, which leaves alone the results experiments already own.
+// Otherwise result is saved as a new LLM test case. It returns the ID of
+// the case holding result.
+func saveResult(id uint, result *models.LLMTestCase, columns []string, query string, args ...interface{}) (uint, error) {
+	savedID := id
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		// The case is locked until the result is saved, so that concurrent
+		// tasks can't both take it
+		var matching int64
+		err := tx.Model(&models.LLMTestCase{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", id).
+			Where(query, args...).
+			Count(&matching).Error
+		if err != nil {
+			return err
+		}
+		if matching > 0 {
+			return tx.Model(&models.LLMTestCase{}).Where("id = ?", id).Select(columns).Updates(result).Error
 		}
+		if err := tx.Create(result).Error; err != nil {
+			return err
+		}
+		savedID = result.ID
 		return nil
 	})
-
-	return taskID, nil
+	return savedID, err
 }
 
-func (s *LLMTestCaseService) RunLLMTestCasesFromDefinitions(promptID, configID uint) (string, error) {
-	var prompt models.Prompt
-	if err := utils.DB.First(&prompt, promptID).Error; err != nil {
+// RunLLMTestCasesFromDefinitions starts a task running and evaluating the
+// test case definitions of the prompt's project, repetitions times each.
+func (s *LLMTestCaseService) RunLLMTestCasesFromDefinitions(promptID, configID uint, concurrency, repetitions int, createdBy string) (string, error) {
+	if err := ValidateRepetitions(repetitions); err != nil {
 		return "", err
 	}
 
-	var config models.LLMConfig
-	if err := utils.DB.First(&config, configID).Error; err != nil {
+	var prompt models.Prompt
+	if err := utils.DB.First(&prompt, promptID).Error; err != nil {
 		return "", err
 	}
 
-	var testCases []models.TestCase
-	if err := utils.DB.Joins("JOIN prompts ON prompts.id = test_cases.prompt_id").
+	var testCaseIDs []uint
+	if err := utils.DB.Model(&models.TestCase{}).
+		Joins("JOIN prompts ON prompts.id = test_cases.prompt_id").
 		Where("prompts.project_id = ?", prompt.ProjectID).
-		Find(&testCases).Error; err != nil {
+		Pluck("test_cases.id", &testCaseIDs).Error; err != nil {
 		return "", err
 	}
 
-	if len(testCases) == 0 {
+	if len(testCaseIDs) == 0 {
 		return "", fmt.Errorf("no test cases found for this project")
 	}
 
-	taskID := GlobalTaskManager.StartTask(len(testCases), func(ctx context.Context, updateProgress func(int, string) error) error {
-		for i, tc := range testCases {
-			if err := updateProgress(i, fmt.Sprintf("Running and Evaluating %d/%d", i+1, len(testCases))); err != nil {
-				return err
-			}
+	// The definitions are fixed when the task starts so a resumed task
+	// runs the same set
+	meta := TaskMeta{
+		CreatedBy: createdBy,
+		ProjectID: prompt.ProjectID,
+		PromptID:  promptID,
+		ConfigID:  configID,
+	}
+	return GlobalTaskManager.StartTask(models.TaskTypeRunDefinitions, meta, testCaseTaskParams{
+		TestCaseIDs: testCaseIDs,
+		ConfigID:    configID,
+		PromptID:    promptID,
+		Concurrency: concurrency,
+		Repetitions: repetitions,
+	}, len(testCaseIDs)*sampleCount(repetitions))
+}
 
-			output, err := s.LLMService.RunPrompt(ctx, config, prompt.Content, tc.Input)
-			if err != nil {
-				output = "Error: " + err.Error()
-			}
+func (s *LLMTestCaseService) runDefinitionsTask(data json.RawMessage) (TaskFunc, error) {
+	var params testCaseTaskParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+
+	var prompt models.Prompt
+	if err := utils.DB.First(&prompt, params.PromptID).Error; err != nil {
+		return nil, err
+	}
+
+	var config models.LLMConfig
+	if err := utils.DB.First(&config, params.ConfigID).Error; err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, run *TaskRun, updateProgress func(int, string) error) error {
+		experiments := newTaskExperiments(run.TaskID, models.ExperimentKindRunEvaluate, &config, &config)
 
-			reason, isPass, err := s.LLMService.EvaluateTestCase(ctx, config, prompt.Content, tc.Input, output)
+		// Every definition is sampled once before any is sampled again
+		cases := len(params.TestCaseIDs)
+		total := cases * sampleCount(params.Repetitions)
+		return runParallel(ctx, run, total, taskWorkers(params.Concurrency, config), "Running and Evaluating", updateProgress, func(ctx context.Context, i int) (uint, error) {
+			experiment, err := experiments.get(prompt.ID)
 			if err != nil {
-				reason = "Evaluation Error: " + err.Error()
-				isPass = false
+				return 0, err
 			}
+			return s.runDefinition(ctx, config, config, experiment, params.TestCaseIDs[i%cases], i/cases)
+		})
+	}, nil
+}
+
+// runDefinition runs a test case definition with config, judges the output
+// with judge and saves both as a new LLM test case of experiment, numbered
+// sample among the repetitions of the definition. It is the work of an item
+// of runs from definitions and matrix runs.
+func (s *LLMTestCaseService) runDefinition(ctx context.Context, config, judge models.LLMConfig, experiment *models.Experiment, id uint, sample int) (uint, error) {
+	var tc models.TestCase
+	if err := utils.DB.First(&tc, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, skipItem("test case definition not found")
+		}
+		return 0, err
+	}
+
+	taskID := TaskIDFromContext(ctx)
+	result := models.LLMTestCase{
+		PromptID:         experiment.PromptID,
+		Input:            tc.Input,
+		Conversation:     tc.Conversation,
+		Critical:         tc.Critical,
+		Sample:           sample,
+		TaskID:           taskID,
+		EvalTaskID:       taskID,
+		ExperimentID:     experiment.ID,
+		EvalExperimentID: experiment.ID,
+	}
+
+	resp, runErr := s.LLMService.RunPrompt(ctx, config, experiment.PromptContent, tc.Conversation, tc.Input)
+	if runErr != nil {
+		result.Output = "Error: " + runErr.Error()
+		result.Attempts = AttemptsOf(resp, runErr)
+		result.FailedAttempts = FailedAttemptsOf(resp, runErr)
+	} else {
+		result.Output = resp.Content
+		recordRunUsage(&result, config, resp)
+	}
+
+	reason, isPass, evalResp, evalErr := s.LLMService.EvaluateTestCase(ctx, judge, experiment.PromptContent, tc.Conversation, tc.Input, result.Output)
+	if evalErr != nil {
+		reason = "Evaluation Error: " + evalErr.Error()
+		isPass = false
+		result.EvalAttempts = AttemptsOf(evalResp, evalErr)
+		result.EvalFailedAttempts = FailedAttemptsOf(evalResp, evalErr)
+	} else {
+		recordEvalUsage(&result, judge, evalResp)
+	}
+
+	// Leave items cut short by a stop to be run again on resume
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	result.Evaluation = reason
+	result.IsPass = isPass
+	if err := utils.DB.Create(&result).Error; err != nil {
+		return 0, err
+	}
+	if runErr != nil {
+		return result.ID, runErr
+	}
+	return result.ID, evalErr
+}
+
+// RetryFailedItems re-queues the failed items of a task.
+// Results saved for failed items of a run from definitions or a matrix run
+// are deleted so the retry doesn't leave duplicates behind, and the items a
+// pipeline skipped after a failed item are run again after it.
+func (s *LLMTestCaseService) RetryFailedItems(taskID string) error {
+	task, ok := GlobalTaskManager.GetTask(taskID)
+	if !ok {
+		return fmt.Errorf("task not found")
+	}
 
-			result := models.LLMTestCase{
-				PromptID:   promptID,
-				Input:      tc.Input,
-				Output:     output,
-				Evaluation: reason,
-				IsPass:     isPass,
+	var discard func(tx *gorm.DB, failed []models.TaskItem) error
+	if task.Type == models.TaskTypePipeline {
+		var err error
+		if discard, err = pipelineRetryDiscard(task.Params); err != nil {
+			return err
+		}
+	}
+	if task.Type == models.TaskTypeRunDefinitions || task.Type == models.TaskTypeRunMatrix {
+		discard = func(tx *gorm.DB, failed []models.TaskItem) error {
+			var ids []uint
+			for _, item := range failed {
+				if item.RefID != 0 {
+					ids = append(ids, item.RefID)
+				}
+			}
+			if len(ids) == 0 {
+				return nil
 			}
-			utils.DB.Create(&result)
+			return tx.Delete(&models.LLMTestCase{}, ids).Error
 		}
-		return nil
-	})
+	}
+
+	return GlobalTaskManager.RetryFailedItems(taskID, discard)
+}
+
+// recordRunUsage stores the usage and cost of the call that produced the output.
+func recordRunUsage(testCase *models.LLMTestCase, config models.LLMConfig, resp *LLMResponse) {
+	testCase.PromptTokens = resp.Usage.PromptTokens
+	testCase.CompletionTokens = resp.Usage.CompletionTokens
+	testCase.LatencyMs = resp.Latency.Milliseconds()
+	testCase.FinishReason = resp.FinishReason
+	testCase.Cost = CalculateCost(config, resp.Usage)
+	testCase.Attempts = resp.Attempts
+	testCase.FailedAttempts = resp.FailedAttempts
+}
+
+// recordEvalUsage stores the usage and cost of the judge call.
+func recordEvalUsage(testCase *models.LLMTestCase, config models.LLMConfig, resp *LLMResponse) {
+	testCase.EvalPromptTokens = resp.Usage.PromptTokens
+	testCase.EvalCompletionTokens = resp.Usage.CompletionTokens
+	testCase.EvalLatencyMs = resp.Latency.Milliseconds()
+	testCase.EvalCost = CalculateCost(config, resp.Usage)
+	testCase.EvalAttempts = resp.Attempts
+	testCase.EvalFailedAttempts = resp.FailedAttempts
+}
+
+// CostSummary aggregates token usage and cost of LLM test case runs and
+// evaluations.
+type CostSummary struct {
+	Count                int64   `json:"count"`
+	PromptTokens         int64   `json:"prompt_tokens"`
+	CompletionTokens     int64   `json:"completion_tokens"`
+	RunCost              float64 `json:"run_cost"`
+	EvalPromptTokens     int64   `json:"eval_prompt_tokens"`
+	EvalCompletionTokens int64   `json:"eval_completion_tokens"`
+	EvalCost             float64 `json:"eval_cost"`
+	TotalCost            float64 `json:"total_cost"`
+}
+
+// GetCostSummary sums usage and cost filtered by task, prompt and/or project.
+// For a task, runs it produced and evaluations it produced are both counted.
+func (s *LLMTestCaseService) GetCostSummary(taskID, promptID, projectID string) (*CostSummary, error) {
+	query := utils.DB.Model(&models.LLMTestCase{})
+	if projectID != "" {
+		query = query.Joins("JOIN prompts ON prompts.id = llm_test_cases.prompt_id").Where("prompts.project_id = ?", projectID)
+	}
+	if promptID != "" {
+		query = query.Where("llm_test_cases.prompt_id = ?", promptID)
+	}
+
+	runSelect := "COALESCE(SUM(llm_test_cases.prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(llm_test_cases.completion_tokens), 0) AS completion_tokens, COALESCE(SUM(llm_test_cases.cost), 0) AS run_cost"
+	evalSelect := "COALESCE(SUM(llm_test_cases.eval_prompt_tokens), 0) AS eval_prompt_tokens, COALESCE(SUM(llm_test_cases.eval_completion_tokens), 0) AS eval_completion_tokens, COALESCE(SUM(llm_test_cases.eval_cost), 0) AS eval_cost"
+	var selectArgs []interface{}
+	if taskID != "" {
+		// Only count the side of each row this task is responsible for
+		runSelect = "COALESCE(SUM(CASE WHEN llm_test_cases.task_id = @task THEN llm_test_cases.prompt_tokens ELSE 0 END), 0) AS prompt_tokens, " +
+			"COALESCE(SUM(CASE WHEN llm_test_cases.task_id = @task THEN llm_test_cases.completion_tokens ELSE 0 END), 0) AS completion_tokens, " +
+			"COALESCE(SUM(CASE WHEN llm_test_cases.task_id = @task THEN llm_test_cases.cost ELSE 0 END), 0) AS run_cost"
+		evalSelect = "COALESCE(SUM(CASE WHEN llm_test_cases.eval_task_id = @task THEN llm_test_cases.eval_prompt_tokens ELSE 0 END), 0) AS eval_prompt_tokens, " +
+			"COALESCE(SUM(CASE WHEN llm_test_cases.eval_task_id = @task THEN llm_test_cases.eval_completion_tokens ELSE 0 END), 0) AS eval_completion_tokens, " +
+			"COALESCE(SUM(CASE WHEN llm_test_cases.eval_task_id = @task THEN llm_test_cases.eval_cost ELSE 0 END), 0) AS eval_cost"
+		query = query.Where("(llm_test_cases.task_id = @task OR llm_test_cases.eval_task_id = @task)", sql.Named("task", taskID))
+		selectArgs = append(selectArgs, sql.Named("task", taskID))
+	}
 
-	return taskID, nil
+	var summary CostSummary
+	err := query.Select("COUNT(*) AS count, "+runSelect+", "+evalSelect, selectArgs...).Scan(&summary).Error
+	if err != nil {
+		return nil, err
+	}
+	summary.TotalCost = summary.RunCost + summary.EvalCost
+	return &summary, nil
 }
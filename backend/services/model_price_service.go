@@ -0,0 +1,65 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"codeagent-backend/utils"
+)
+
+type ModelPriceService struct{}
+
+func (s *ModelPriceService) CreateModelPrice(price *models.ModelPrice) error {
+	return utils.DB.Create(price).Error
+}
+
+func (s *ModelPriceService) GetModelPrices(page, pageSize int) ([]models.ModelPrice, int64, error) {
+	var prices []models.ModelPrice
+	var total int64
+
+	query := utils.DB.Model(&models.ModelPrice{})
+	query.Count(&total)
+
+	err := query.Order("id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&prices).Error
+	return prices, total, err
+}
+
+func (s *ModelPriceService) GetModelPrice(id string) (*models.ModelPrice, error) {
+	var price models.ModelPrice
+	err := utils.DB.First(&price, id).Error
+	return &price, err
+}
+
+func (s *ModelPriceService) UpdateModelPrice(price *models.ModelPrice) error {
+	return utils.DB.Save(price).Error
+}
+
+func (s *ModelPriceService) DeleteModelPrice(price *models.ModelPrice) error {
+	return utils.DB.Delete(price).Error
+}
+
+func (s *ModelPriceService) BatchDeleteModelPrices(ids []uint) error {
+	return utils.DB.Delete(&models.ModelPrice{}, ids).Error
+}
+
+// findModelPrice looks up the price for a config's model, preferring an entry
+// for its provider over one that applies to any provider.
+func findModelPrice(config models.LLMConfig) (*models.ModelPrice, bool) {
+	var prices []models.ModelPrice
+	utils.DB.Where("model_name = ? AND (provider = ? OR provider = '' OR provider IS NULL)", config.ModelName, ProviderName(config)).
+		Order("provider desc").
+		Limit(1).
+		Find(&prices)
+	if len(prices) == 0 {
+		return nil, false
+	}
+	return &prices[0], true
+}
+
+// CalculateCost prices the usage of a call made with config. Models without a
+// pricing entry cost nothing.
+func CalculateCost(config models.LLMConfig, usage TokenUsage) float64 {
+	price, ok := findModelPrice(config)
+	if !ok {
+		return 0
+	}
+	return (float64(usage.PromptTokens)*price.InputPricePerM + float64(usage.CompletionTokens)*price.OutputPricePerM) / 1e6
+}
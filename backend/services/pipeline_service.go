@@ -0,0 +1,326 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"codeagent-backend/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type PipelineService struct {
+	LLMTestCaseService *LLMTestCaseService
+}
+
+func init() {
+	s := &PipelineService{LLMTestCaseService: &LLMTestCaseService{LLMService: new(LLMService)}}
+	RegisterTaskHandler(models.TaskTypePipeline, s.pipelineTask)
+}
+
+func (s *PipelineService) CreatePipeline(pipeline *models.Pipeline) error {
+	return utils.DB.Create(pipeline).Error
+}
+
+func (s *PipelineService) GetPipelines(projectID string, page, pageSize int) ([]models.Pipeline, int64, error) {
+	var pipelines []models.Pipeline
+	var total int64
+
+	query := utils.DB.Model(&models.Pipeline{})
+	if projectID != "" {
+		query = query.Where("project_id = ?", projectID)
+	}
+
+	query.Count(&total)
+	err := query.Order("id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&pipelines).Error
+	return pipelines, total, err
+}
+
+func (s *PipelineService) GetPipeline(id string) (*models.Pipeline, error) {
+	var pipeline models.Pipeline
+	err := utils.DB.First(&pipeline, id).Error
+	return &pipeline, err
+}
+
+func (s *PipelineService) UpdatePipeline(pipeline *models.Pipeline) error {
+	return utils.DB.Save(pipeline).Error
+}
+
+func (s *PipelineService) DeletePipeline(pipeline *models.Pipeline) error {
+	return utils.DB.Delete(pipeline).Error
+}
+
+func (s *PipelineService) BatchDeletePipelines(ids []uint) error {
+	return utils.DB.Delete(&models.Pipeline{}, ids).Error
+}
+
+// ValidatePipelineStages reports an error if stages don't form a runnable
+// pipeline.
+func ValidatePipelineStages(stages []models.PipelineStage) error {
+	if len(stages) == 0 {
+		return fmt.Errorf("a pipeline needs at least one stage")
+	}
+	for i, stage := range stages {
+		switch stage.Type {
+		case models.PipelineStageGenerate:
+			if i != 0 {
+				return fmt.Errorf("stage %d: generate can only be the first stage", i+1)
+			}
+			if stage.Count <= 0 {
+				return fmt.Errorf("stage %d: generate needs a count", i+1)
+			}
+		case models.PipelineStageRun:
+			if err := ValidateRepetitions(stage.Repetitions); err != nil {
+				return fmt.Errorf("stage %d: %w", i+1, err)
+			}
+		case models.PipelineStageEvaluate:
+		default:
+			return fmt.Errorf("stage %d: unsupported stage type: %s", i+1, stage.Type)
+		}
+		if stage.Type != models.PipelineStageRun && stage.Repetitions != 0 {
+			return fmt.Errorf("stage %d: repetitions only apply to run stages", i+1)
+		}
+		if stage.ConfigID == 0 {
+			return fmt.Errorf("stage %d: config_id is required", i+1)
+		}
+	}
+	return nil
+}
+
+// pipelineTaskParams are the persisted parameters of pipeline tasks. The
+// pipeline is copied when it starts, so editing it doesn't affect runs in
+// progress or their resumption.
+type pipelineTaskParams struct {
+	PipelineID  uint                   `json:"pipeline_id"`
+	PromptIDs   []uint                 `json:"prompt_ids"`
+	TestCaseIDs []uint                 `json:"test_case_ids,omitempty"` // Input of the first stage unless it generates
+	Stages      []models.PipelineStage `json:"stages"`
+}
+
+// RunPipeline starts a task running every stage of a pipeline in order.
+func (s *PipelineService) RunPipeline(id string, createdBy string) (string, error) {
+	pipeline, err := s.GetPipeline(id)
+	if err != nil {
+		return "", err
+	}
+	if err := ValidatePipelineStages(pipeline.Stages); err != nil {
+		return "", err
+	}
+
+	params := pipelineTaskParams{
+		PipelineID: pipeline.ID,
+		PromptIDs:  pipeline.PromptIDs,
+		Stages:     pipeline.Stages,
+	}
+	if len(params.PromptIDs) == 0 {
+		if err := utils.DB.Model(&models.Prompt{}).Where("project_id = ?", pipeline.ProjectID).Order("id").Pluck("id", &params.PromptIDs).Error; err != nil {
+			return "", err
+		}
+	}
+	if len(params.PromptIDs) == 0 {
+		return "", fmt.Errorf("no prompts found for this pipeline")
+	}
+
+	if params.Stages[0].Type != models.PipelineStageGenerate {
+		// Results earlier runs saved apart from their cases are left out, so
+		// that a pipeline doesn't run its own results again
+		err := utils.DB.Model(&models.LLMTestCase{}).
+			Where("prompt_id IN ? AND COALESCE(source_test_case_id, 0) = 0 AND COALESCE(sample, 0) = 0", params.PromptIDs).
+			Order("id").
+			Pluck("id", &params.TestCaseIDs).Error
+		if err != nil {
+			return "", err
+		}
+		if len(params.TestCaseIDs) == 0 {
+			return "", fmt.Errorf("no LLM test cases found for this pipeline")
+		}
+	}
+
+	meta := TaskMeta{
+		CreatedBy: createdBy,
+		ProjectID: pipeline.ProjectID,
+		ConfigID:  params.Stages[0].ConfigID,
+	}
+	if len(params.PromptIDs) == 1 {
+		meta.PromptID = params.PromptIDs[0]
+	}
+	return GlobalTaskManager.StartTask(models.TaskTypePipeline, meta, params, pipelineTotal(params, 0, 0))
+}
+
+// pipelineTotal estimates the number of items of a pipeline from stage
+// `from` on, given the number of test cases entering that stage (ignored for
+// a generate stage, which works on prompts).
+func pipelineTotal(params pipelineTaskParams, from int, cases int) int {
+	if from == 0 {
+		cases = len(params.TestCaseIDs)
+	}
+	total := 0
+	for _, stage := range params.Stages[from:] {
+		if stage.Type == models.PipelineStageGenerate {
+			total += len(params.PromptIDs)
+			cases = len(params.PromptIDs) * stage.Count
+			continue
+		}
+		cases *= stageSamples(stage)
+		total += cases
+	}
+	return total
+}
+
+// stageSamples is the number of items a stage runs for each of its input
+// test cases.
+func stageSamples(stage models.PipelineStage) int {
+	if stage.Type != models.PipelineStageRun {
+		return 1
+	}
+	return sampleCount(stage.Repetitions)
+}
+
+func (s *PipelineService) pipelineTask(data json.RawMessage) (TaskFunc, error) {
+	var params pipelineTaskParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+
+	configs := make([]models.LLMConfig, len(params.Stages))
+	for i, stage := range params.Stages {
+		if err := utils.DB.First(&configs[i], stage.ConfigID).Error; err != nil {
+			return nil, fmt.Errorf("stage %d: %w", i+1, err)
+		}
+	}
+
+	return func(ctx context.Context, run *TaskRun, updateProgress func(int, string) error) error {
+		testCaseIDs := params.TestCaseIDs
+		var blockedItems map[int]string // Reasons to skip inputs whose previous stage item didn't succeed
+		done := 0                       // Items of the stages before the current one
+
+		for i, stage := range params.Stages {
+			config := configs[i]
+			label := fmt.Sprintf("Stage %d/%d (%s)", i+1, len(params.Stages), stage.Type)
+			stageProgress := func(current int, msg string) error {
+				return updateProgress(done+current, msg)
+			}
+
+			var items int
+			var err error
+			switch stage.Type {
+			case models.PipelineStageGenerate:
+				items = len(params.PromptIDs)
+				err = runParallel(ctx, run.Stage(i), items, taskWorkers(stage.Concurrency, config), label, stageProgress, func(ctx context.Context, j int) (uint, error) {
+					return s.LLMTestCaseService.generateTestCases(ctx, config, params.PromptIDs[j], stage.Count)
+				})
+				if err == nil {
+					// The generated cases are the input of the next stage. They
+					// are ordered by ID so that cases generated by a retry are
+					// appended and the items of earlier cases keep their index.
+					testCaseIDs = nil
+					err = utils.DB.Model(&models.LLMTestCase{}).Where("gen_task_id = ?", run.TaskID).Order("id").Pluck("id", &testCaseIDs).Error
+				}
+				if err == nil {
+					total := done + items + pipelineTotal(params, i+1, len(testCaseIDs))
+					GlobalTaskManager.UpdateTask(run.TaskID, func(t *Task) {
+						t.Total = total
+					})
+				}
+			case models.PipelineStageRun:
+				// The samples of a case follow each other, so that the items
+				// of cases generated by a retry are appended
+				ids, blocked, samples := testCaseIDs, blockedItems, stageSamples(stage)
+				items = len(ids) * samples
+				experiments := newTaskExperiments(run.TaskID, models.ExperimentKindRun, &config, nil)
+				err = runParallel(ctx, run.Stage(i), items, taskWorkers(stage.Concurrency, config), label, stageProgress, func(ctx context.Context, j int) (uint, error) {
+					if reason, ok := blocked[j/samples]; ok {
+						return 0, skipItem(reason)
+					}
+					return s.LLMTestCaseService.runTestCase(ctx, config, experiments, ids[j/samples], j%samples)
+				})
+				if err == nil {
+					testCaseIDs, blockedItems, err = stageOutput(run.Stage(i), i, items)
+				}
+			case models.PipelineStageEvaluate:
+				ids, blocked := testCaseIDs, blockedItems
+				items = len(ids)
+				experiments := newTaskExperiments(run.TaskID, models.ExperimentKindEvaluate, nil, &config)
+				err = runParallel(ctx, run.Stage(i), items, taskWorkers(stage.Concurrency, config), label, stageProgress, func(ctx context.Context, j int) (uint, error) {
+					if reason, ok := blocked[j]; ok {
+						return 0, skipItem(reason)
+					}
+					return s.LLMTestCaseService.evaluateTestCase(ctx, config, experiments, ids[j])
+				})
+				if err == nil {
+					testCaseIDs, blockedItems, err = stageOutput(run.Stage(i), i, items)
+				}
+			default:
+				err = fmt.Errorf("unsupported stage type: %s", stage.Type)
+			}
+			if err != nil {
+				return err
+			}
+			done += items
+		}
+		return nil
+	}, nil
+}
+
+// stageOutput returns the input of the stage following a run or evaluate
+// stage: the result of each of its items, index for index, so that an item
+// keeps its index through the stages, or its samples' indexes through a run
+// with repetitions. Inputs whose item didn't succeed are
+// blocked with a reason instead, to skip rather than work on missing or
+// stale results.
+func stageOutput(run *TaskRun, stage, items int) ([]uint, map[int]string, error) {
+	recorded, err := run.Items()
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([]uint, items)
+	blocked := make(map[int]string)
+	for j := range ids {
+		item, ok := recorded[j]
+		switch {
+		case !ok:
+			blocked[j] = fmt.Sprintf("not finished in stage %d", stage+1)
+		case item.Status != models.TaskItemSucceeded:
+			blocked[j] = fmt.Sprintf("%s in stage %d", item.Status, stage+1)
+		default:
+			ids[j] = item.RefID
+		}
+	}
+	return ids, blocked, nil
+}
+
+// pipelineRetryDiscard returns the discard of a retry of the failed items of
+// a pipeline task. The items later stages skipped because of a failed item
+// are discarded with it, so that its retry feeds forward through the
+// remaining stages.
+func pipelineRetryDiscard(data json.RawMessage) (func(tx *gorm.DB, failed []models.TaskItem) error, error) {
+	var params pipelineTaskParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+
+	return func(tx *gorm.DB, failed []models.TaskItem) error {
+		var indexes []int
+		for _, item := range failed {
+			stage, j := item.ItemIndex/taskStageSize, item.ItemIndex%taskStageSize
+			// Cases generated by a retry are new items of the next stage
+			if stage >= len(params.Stages) || params.Stages[stage].Type == models.PipelineStageGenerate {
+				continue
+			}
+			// Follow the item through the samples later stages took of it
+			first, count := j, 1
+			for later := stage + 1; later < len(params.Stages); later++ {
+				samples := stageSamples(params.Stages[later])
+				first, count = first*samples, count*samples
+				for k := first; k < first+count; k++ {
+					indexes = append(indexes, later*taskStageSize+k)
+				}
+			}
+		}
+		if len(indexes) == 0 {
+			return nil
+		}
+		return tx.Where("task_id = ? AND item_index IN ?", failed[0].TaskID, indexes).Delete(&models.TaskItem{}).Error
+	}, nil
+}
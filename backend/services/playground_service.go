@@ -0,0 +1,125 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"codeagent-backend/utils"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type PlaygroundService struct {
+	LLMService *LLMService
+}
+
+// PlaygroundResult is the output of one config for a playground run.
+type PlaygroundResult struct {
+	ConfigID       uint                `json:"config_id"`
+	ConfigName     string              `json:"config_name"`
+	ModelName      string              `json:"model_name"`
+	Output         string              `json:"output"`
+	LatencyMs      int64               `json:"latency_ms"`
+	Usage          TokenUsage          `json:"usage"`
+	FinishReason   string              `json:"finish_reason"`
+	Cost           float64             `json:"cost"`
+	Attempts       int                 `json:"attempts"`
+	FailedAttempts []models.LLMAttempt `json:"failed_attempts,omitempty"`
+	Error          string              `json:"error,omitempty"`
+	LLMTestCaseID  uint                `json:"llm_test_case_id,omitempty"` // Set when the result was saved
+}
+
+// Run executes a prompt on an ad-hoc input against every config in parallel.
+// The prompt is loaded from promptID when set, otherwise content is used as is.
+// Results are only persisted as LLMTestCase rows when save is true, which
+// requires a prompt ID to attach them to.
+func (s *PlaygroundService) Run(ctx context.Context, promptID uint, content string, history []models.ChatTurn, input string, configIDs []uint, save bool) ([]PlaygroundResult, error) {
+	if promptID != 0 {
+		var prompt models.Prompt
+		if err := utils.DB.First(&prompt, promptID).Error; err != nil {
+			return nil, err
+		}
+		content = prompt.Content
+	}
+
+	var configs []models.LLMConfig
+	if err := utils.DB.Find(&configs, configIDs).Error; err != nil {
+		return nil, err
+	}
+
+	// Keep results in the order the configs were requested
+	configByID := make(map[uint]models.LLMConfig, len(configs))
+	for _, config := range configs {
+		configByID[config.ID] = config
+	}
+	for _, id := range configIDs {
+		if _, ok := configByID[id]; !ok {
+			return nil, fmt.Errorf("config %d not found", id)
+		}
+	}
+
+	results := make([]PlaygroundResult, len(configIDs))
+	var wg sync.WaitGroup
+	for i, id := range configIDs {
+		wg.Add(1)
+		go func(i int, config models.LLMConfig) {
+			defer wg.Done()
+			results[i] = s.runOne(ctx, config, content, history, input)
+		}(i, configByID[id])
+	}
+	wg.Wait()
+
+	if save {
+		for i := range results {
+			if results[i].Error != "" {
+				continue
+			}
+			testCase := models.LLMTestCase{
+				PromptID:         promptID,
+				Input:            input,
+				Conversation:     history,
+				Output:           results[i].Output,
+				PromptTokens:     results[i].Usage.PromptTokens,
+				CompletionTokens: results[i].Usage.CompletionTokens,
+				LatencyMs:        results[i].LatencyMs,
+				FinishReason:     results[i].FinishReason,
+				Cost:             results[i].Cost,
+				Attempts:         results[i].Attempts,
+				FailedAttempts:   results[i].FailedAttempts,
+			}
+			if err := utils.DB.Create(&testCase).Error; err != nil {
+				return results, err
+			}
+			results[i].LLMTestCaseID = testCase.ID
+		}
+	}
+
+	return results, nil
+}
+
+func (s *PlaygroundService) runOne(ctx context.Context, config models.LLMConfig, content string, history []models.ChatTurn, input string) PlaygroundResult {
+	result := PlaygroundResult{
+		ConfigID:   config.ID,
+		ConfigName: config.Name,
+		ModelName:  config.ModelName,
+	}
+
+	start := time.Now()
+	resp, err := s.LLMService.RunPrompt(ctx, config, content, history, input)
+	if err != nil {
+		result.LatencyMs = time.Since(start).Milliseconds()
+		result.Error = err.Error()
+		result.Attempts = AttemptsOf(resp, err)
+		result.FailedAttempts = FailedAttemptsOf(resp, err)
+		return result
+	}
+
+	result.Output = resp.Content
+	result.Usage = resp.Usage
+	result.FinishReason = resp.FinishReason
+	result.LatencyMs = resp.Latency.Milliseconds()
+	result.Cost = CalculateCost(config, resp.Usage)
+	result.Attempts = resp.Attempts
+	result.FailedAttempts = resp.FailedAttempts
+	return result
+}
@@ -1,64 +1,407 @@
 package services
 
 import (
+	"codeagent-backend/models"
+	"codeagent-backend/utils"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-type TaskStatus string
+// TaskFunc is the work of a task. Items finished by an earlier run of the
+// same task are reported by run.Completed and should not be run again.
+type TaskFunc func(ctx context.Context, run *TaskRun, updateProgress func(current int, msg string) error) error
 
-const (
-	TaskStatusPending   TaskStatus = "pending"
-	TaskStatusRunning   TaskStatus = "running"
-	TaskStatusCompleted TaskStatus = "completed"
-	TaskStatusFailed    TaskStatus = "failed"
-	TaskStatusStopped   TaskStatus = "stopped"
+// TaskHandler builds the work of a task from its persisted params. Handlers
+// are registered per task type so that interrupted tasks can be resumed.
+type TaskHandler func(params json.RawMessage) (TaskFunc, error)
+
+var (
+	taskHandlersMu sync.RWMutex
+	taskHandlers   = map[string]TaskHandler{}
 )
 
+// RegisterTaskHandler makes tasks of the given type startable and resumable.
+func RegisterTaskHandler(taskType string, handler TaskHandler) {
+	taskHandlersMu.Lock()
+	defer taskHandlersMu.Unlock()
+	taskHandlers[taskType] = handler
+}
+
+func taskFunc(taskType string, params json.RawMessage) (TaskFunc, error) {
+	taskHandlersMu.RLock()
+	handler, ok := taskHandlers[taskType]
+	taskHandlersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown task type: %s", taskType)
+	}
+	return handler(params)
+}
+
+// Task is a task being run by this process.
 type Task struct {
-	ID        string      `json:"id"`
-	Status    TaskStatus  `json:"status"`
-	Progress  int         `json:"progress"`
-	Total     int         `json:"total"`
-	Message   string      `json:"message"`
-	Result    interface{} `json:"result,omitempty"`
-	Error     string      `json:"error,omitempty"`
-	CreatedAt time.Time   `json:"created_at"`
+	models.Task
 	
 	mu     sync.RWMutex
-	cancel context.CancelFunc
+	cancel context.CancelCauseFunc
+
+	subsMu sync.Mutex
+	subs   map[chan TaskEvent]struct{}
+	closed bool // Finished, no more events will be published
+}
+
+// TaskEvent is a change to a running task, delivered to subscribers.
+type TaskEvent struct {
+	Task *models.Task     // Snapshot of the task after a progress or status change
+	Item *models.TaskItem // An item that was just completed
+}
+
+// taskEventBuffer is how many events a subscriber may lag behind before
+// events are dropped for it. Progress events are snapshots, so a dropped
+// event is made up for by the next one.
+const taskEventBuffer = 64
+
+func (t *Task) publish(event TaskEvent) {
+	t.subsMu.Lock()
+	defer t.subsMu.Unlock()
+	for ch := range t.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// closeSubscribers ends every subscription once the task has finished.
+func (t *Task) closeSubscribers() {
+	t.subsMu.Lock()
+	defer t.subsMu.Unlock()
+	for ch := range t.subs {
+		close(ch)
+	}
+	t.subs = nil
+	t.closed = true
+}
+
+// taskStageSize is the number of item indexes reserved for each stage of a
+// multi-stage task, see TaskRun.Stage.
+const taskStageSize = 1 << 20
+
+// TaskRun tracks which items of a task are finished.
+type TaskRun struct {
+	TaskID string
+
+	task   *Task
+	offset int // Index of the first item of the stage this run covers
+	items  *completedItems
+}
+
+type completedItems struct {
+	mu      sync.Mutex
+	indexes map[int]bool
+}
+
+func newTaskRun(task *Task, indexes []int) *TaskRun {
+	items := &completedItems{indexes: make(map[int]bool, len(indexes))}
+	for _, index := range indexes {
+		items.indexes[index] = true
+	}
+	return &TaskRun{TaskID: task.ID, task: task, items: items}
+}
+
+// Stage returns a view of the run for one stage of a multi-stage task. Item
+// indexes passed to and reported by the view are relative to the stage.
+func (r *TaskRun) Stage(stage int) *TaskRun {
+	return &TaskRun{TaskID: r.TaskID, task: r.task, offset: stage * taskStageSize, items: r.items}
+}
+
+// Completed reports whether the item at index was finished by this or an
+// earlier run of the task.
+func (r *TaskRun) Completed(index int) bool {
+	r.items.mu.Lock()
+	defer r.items.mu.Unlock()
+	return r.items.indexes[r.offset+index]
+}
+
+// CompletedCount returns the number of finished items of the stage.
+func (r *TaskRun) CompletedCount() int {
+	r.items.mu.Lock()
+	defer r.items.mu.Unlock()
+	count := 0
+	for index := range r.items.indexes {
+		if index >= r.offset && index < r.offset+taskStageSize {
+			count++
+		}
+	}
+	return count
+}
+
+// Items loads the recorded items of the stage, keyed by their index within
+// the stage.
+func (r *TaskRun) Items() (map[int]models.TaskItem, error) {
+	var items []models.TaskItem
+	if err := utils.DB.Where("task_id = ? AND item_index >= ? AND item_index < ?", r.TaskID, r.offset, r.offset+taskStageSize).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	byIndex := make(map[int]models.TaskItem, len(items))
+	for _, item := range items {
+		byIndex[item.ItemIndex-r.offset] = item
+	}
+	return byIndex, nil
 }
 
+// SkipItemError is returned by the work of an item that was not attempted,
+// to record it as skipped rather than failed.
+type SkipItemError struct {
+	Reason string
+}
+
+func (e *SkipItemError) Error() string {
+	return e.Reason
+}
+
+func skipItem(reason string) error {
+	return &SkipItemError{Reason: reason}
+}
+
+// CompleteItem records the outcome of the item at index. refID is the LLM
+// test case the item created or updated. The item failed if err is set,
+// unless err is a *SkipItemError.
+func (r *TaskRun) CompleteItem(index int, refID uint, err error) {
+	index += r.offset
+	item := models.TaskItem{
+		TaskID:    r.TaskID,
+		ItemIndex: index,
+		RefID:     refID,
+		Status:    models.TaskItemSucceeded,
+	}
+	var skipErr *SkipItemError
+	if errors.As(err, &skipErr) {
+		item.Status = models.TaskItemSkipped
+		item.Reason = skipErr.Reason
+	} else if err != nil {
+		item.Status = models.TaskItemFailed
+		item.Error = err.Error()
+		item.Attempts = FailedAttemptsOf(nil, err)
+	}
+	if dbErr := utils.DB.Create(&item).Error; dbErr != nil {
+		log.Printf("Failed to record item %d of task %s: %v", index, r.TaskID, dbErr)
+	}
+	if r.task != nil {
+		r.task.publish(TaskEvent{Item: &item})
+	}
+
+	r.items.mu.Lock()
+	defer r.items.mu.Unlock()
+	r.items.indexes[index] = true
+}
+
+type taskIDKey struct{}
+
+// TaskIDFromContext returns the ID of the task whose run function received ctx.
+func TaskIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(taskIDKey{}).(string)
+	return id
+}
+
+// TaskManager runs tasks in the background and persists their state, so
+// that tasks outlive the process that ran them.
 type TaskManager struct {
-	tasks sync.Map
+	tasks sync.Map // Tasks running in this process
 }
 
 var GlobalTaskManager = &TaskManager{}
 
-func (tm *TaskManager) StartTask(total int, runFunc func(ctx context.Context, updateProgress func(current int, msg string) error) error) string {
-	id := uuid.New().String()
-	ctx, cancel := context.WithCancel(context.Background())
+// TaskMeta describes who started a task and what it works on.
+type TaskMeta struct {
+	CreatedBy string
+	ProjectID uint
+	PromptID  uint
+	ConfigID  uint
+}
+
+// StartTask persists a new task of the given type and runs it in the
+// background. params are stored with the task and passed to the handler
+// registered for its type.
+func (tm *TaskManager) StartTask(taskType string, meta TaskMeta, params interface{}, total int) (string, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+
+	runFunc, err := taskFunc(taskType, data)
+	if err != nil {
+		return "", err
+	}
+
+	task := &Task{Task: models.Task{
+		ID:     uuid.New().String(),
+		Type:   taskType,
+		Status: models.TaskStatusPending,
+		Total:  total,
+		Params: data,
+
+		CreatedBy: meta.CreatedBy,
+		ProjectID: meta.ProjectID,
+		PromptID:  meta.PromptID,
+		ConfigID:  meta.ConfigID,
+	}}
+	if err := utils.DB.Create(&task.Task).Error; err != nil {
+		return "", err
+	}
+
+	if err := tm.run(startTaskContext(task), task, runFunc, newTaskRun(task, nil)); err != nil {
+		return "", err
+	}
+	return task.ID, nil
+}
+
+// ResumeTask runs a paused, interrupted, stopped or failed task again,
+// skipping the items it already finished.
+func (tm *TaskManager) ResumeTask(id string) error {
+	return tm.resume(id, false, nil)
+}
+
+// RetryFailedItems runs the failed items of a task that is not running
+// again. The items' previous outcomes are discarded once the task is known
+// to be resumable. discard, if set, is called in the same transaction with
+// the failed items to remove what they left behind.
+func (tm *TaskManager) RetryFailedItems(id string, discard func(tx *gorm.DB, failed []models.TaskItem) error) error {
+	return tm.resume(id, true, func(tx *gorm.DB) error {
+		var failed []models.TaskItem
+		if err := tx.Where("task_id = ? AND status = ?", id, models.TaskItemFailed).Order("item_index").Find(&failed).Error; err != nil {
+			return err
+		}
+		if len(failed) == 0 {
+			return fmt.Errorf("task has no failed items")
+		}
+		if discard != nil {
+			if err := discard(tx, failed); err != nil {
+				return err
+			}
+		}
+		return tx.Where("task_id = ? AND status = ?", id, models.TaskItemFailed).Delete(&models.TaskItem{}).Error
+	})
+}
+
+// taskResult counts the recorded items of a task by outcome. The items
+// themselves are served by TaskService.GetTaskItems.
+func taskResult(id string) (models.TaskResult, error) {
+	result := models.TaskResult{}
+	var counts []struct {
+		Status string
+		Count  int
+	}
+	err := utils.DB.Model(&models.TaskItem{}).
+		Select("status, COUNT(*) AS count").
+		Where("task_id = ?", id).
+		Group("status").
+		Scan(&counts).Error
+	if err != nil {
+		return result, err
+	}
+	for _, count := range counts {
+		switch count.Status {
+		case models.TaskItemSkipped:
+			result.Skipped += count.Count
+		case models.TaskItemFailed:
+			result.Failed += count.Count
+		default:
+			result.Succeeded += count.Count
+		}
+	}
+	return result, nil
+}
 
-	task := &Task{
-		ID:        id,
-		Status:    TaskStatusPending,
-		Total:     total,
-		CreatedAt: time.Now(),
-		cancel:    cancel,
+// resume runs a stored task again. prepare, if set, runs in a transaction
+// once the task is known to be resumable and reserved against concurrent
+// runs, before its finished items are loaded.
+func (tm *TaskManager) resume(id string, allowCompleted bool, prepare func(tx *gorm.DB) error) error {
+	var stored models.Task
+	if err := utils.DB.First(&stored, "id = ?", id).Error; err != nil {
+		return err
 	}
+	switch stored.Status {
+	case models.TaskStatusPaused, models.TaskStatusInterrupted, models.TaskStatusStopped, models.TaskStatusFailed:
+	case models.TaskStatusCompleted:
+		if !allowCompleted {
+			return fmt.Errorf("task is %s and cannot be resumed", stored.Status)
+		}
+	default:
+		return fmt.Errorf("task is %s and cannot be resumed", stored.Status)
+	}
+
+	runFunc, err := taskFunc(stored.Type, stored.Params)
+	if err != nil {
+		return err
+	}
+
+	stored.Status = models.TaskStatusPending
+	stored.Error = ""
+	task := &Task{Task: stored}
+	// The task can be paused or stopped as soon as it is reserved
+	ctx := startTaskContext(task)
+	if _, running := tm.tasks.LoadOrStore(id, task); running {
+		task.cancel(nil)
+		return fmt.Errorf("task is already running")
+	}
+
+	var indexes []int
+	err = utils.DB.Transaction(func(tx *gorm.DB) error {
+		if prepare != nil {
+			if err := prepare(tx); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.TaskItem{}).Where("task_id = ?", id).Pluck("item_index", &indexes).Error
+	})
+	if err != nil {
+		tm.tasks.Delete(id)
+		task.cancel(nil)
+		return err
+	}
+	task.mu.Lock()
+	task.Progress = len(indexes)
+	task.mu.Unlock()
+	return tm.run(ctx, task, runFunc, newTaskRun(task, indexes))
+}
+
+// startTaskContext creates the context the work of task runs in. Its
+// cancel func is set before the task is visible to PauseTask and StopTask.
+func startTaskContext(task *Task) context.Context {
+	ctx, cancel := context.WithCancelCause(context.WithValue(context.Background(), taskIDKey{}, task.ID))
+	task.cancel = cancel
+	return ctx
+}
+
+func (tm *TaskManager) run(ctx context.Context, task *Task, runFunc TaskFunc, run *TaskRun) error {
+	id := task.ID
+	cancel := task.cancel
 
-	tm.tasks.Store(id, task)
+	// Resumed tasks are reserved by resume already
+	if val, running := tm.tasks.LoadOrStore(id, task); running && val.(*Task) != task {
+		cancel(nil)
+		return fmt.Errorf("task is already running")
+	}
 
 	go func() {
-		defer cancel()
+		defer cancel(nil)
+		defer task.closeSubscribers()
+		defer tm.tasks.Delete(id)
 		
-		// Update status to running
+		// Update status to running, unless paused or stopped while pending
 		tm.UpdateTask(id, func(t *Task) {
-			t.Status = TaskStatusRunning
+			if t.Status != models.TaskStatusPending {
+				return
+			}
+			t.Status = models.TaskStatusRunning
 			t.Message = "Started"
 		})
 
@@ -81,74 +424,242 @@ func (tm *TaskManager) StartTask(total int, runFunc func(ctx context.Context, up
 		defer func() {
 			if r := recover(); r != nil {
 				tm.UpdateTask(id, func(t *Task) {
-					t.Status = TaskStatusFailed
+					t.Status = models.TaskStatusFailed
 					t.Error = fmt.Sprintf("Panic: %v", r)
 				})
 			}
 		}()
 
-		err := runFunc(ctx, updateProgress)
+		err := runFunc(ctx, run, updateProgress)
 		
+		result, resultErr := taskResult(id)
+		if resultErr != nil {
+			log.Printf("Failed to summarize task %s: %v", id, resultErr)
+		}
+		regression := false
+		if err == nil && ctx.Err() == nil && resultErr == nil {
+			gates, gateErr := taskGates(id)
+			if gateErr != nil {
+				log.Printf("Failed to compare task %s with baselines: %v", id, gateErr)
+			}
+			result.Gates = gates
+			for _, gate := range gates {
+				regression = regression || gate.Regression
+			}
+		}
+
 		tm.UpdateTask(id, func(t *Task) {
-			if ctx.Err() != nil {
+			if resultErr == nil {
+				t.Result = result
+			}
+
+			if context.Cause(ctx) == errTaskPaused && t.Status != models.TaskStatusStopped {
+				// Items cut short by the pause were not recorded and run
+				// again on resume
+				t.Status = models.TaskStatusPaused
+				t.Message = fmt.Sprintf("Paused at %d/%d", t.Progress, t.Total)
+			} else if ctx.Err() != nil {
 				// Already handled by StopTask or cancelled
-				if t.Status != TaskStatusStopped {
-					t.Status = TaskStatusStopped
+				if t.Status != models.TaskStatusStopped {
+					t.Status = models.TaskStatusStopped
 					t.Message = "Cancelled"
 				}
 			} else if err != nil {
-				t.Status = TaskStatusFailed
+				t.Status = models.TaskStatusFailed
 				t.Error = err.Error()
 			} else {
-				t.Status = TaskStatusCompleted
+				t.Status = models.TaskStatusCompleted
 				t.Progress = t.Total
 				t.Message = "Completed"
+				if result.Failed > 0 {
+					t.Message = fmt.Sprintf("Completed with %d failed items", result.Failed)
+				}
+				if regression {
+					t.Message += ", regression against baseline"
+				}
 			}
 		})
 	}()
-
-	return id
+	return nil
 }
 
+// UpdateTask applies updateFunc to a running task and persists the result.
 func (tm *TaskManager) UpdateTask(id string, updateFunc func(*Task)) {
 	if val, ok := tm.tasks.Load(id); ok {
 		task := val.(*Task)
 		task.mu.Lock()
 		defer task.mu.Unlock()
 		updateFunc(task)
+		if err := utils.DB.Save(&task.Task).Error; err != nil {
+			log.Printf("Failed to save task %s: %v", id, err)
+		}
+		snapshot := task.Task
+		task.publish(TaskEvent{Task: &snapshot})
 	}
 }
 
-func (tm *TaskManager) GetTask(id string) (Task, bool) {
+// Subscribe returns a channel receiving the events of a running task, and a
+// function to end the subscription. The channel is closed when the task
+// finishes, immediately if it is not running. ok is false if the task
+// doesn't exist.
+func (tm *TaskManager) Subscribe(id string) (events <-chan TaskEvent, unsubscribe func(), ok bool) {
+	ch := make(chan TaskEvent, taskEventBuffer)
+
+	val, running := tm.tasks.Load(id)
+	if !running {
+		close(ch)
+		_, exists := tm.GetTask(id)
+		return ch, func() {}, exists
+	}
+
+	task := val.(*Task)
+	task.subsMu.Lock()
+	defer task.subsMu.Unlock()
+	if task.closed {
+		close(ch)
+		return ch, func() {}, true
+	}
+	if task.subs == nil {
+		task.subs = map[chan TaskEvent]struct{}{}
+	}
+	task.subs[ch] = struct{}{}
+
+	return ch, func() {
+		task.subsMu.Lock()
+		defer task.subsMu.Unlock()
+		if _, ok := task.subs[ch]; ok {
+			delete(task.subs, ch)
+			close(ch)
+		}
+	}, true
+}
+
+// GetTask returns a copy of a task, from memory while it runs and from the
+// database otherwise.
+func (tm *TaskManager) GetTask(id string) (models.Task, bool) {
 	if val, ok := tm.tasks.Load(id); ok {
 		task := val.(*Task)
 		task.mu.RLock()
 		defer task.mu.RUnlock()
-		// Return a copy
-		return Task{
-			ID:        task.ID,
-			Status:    task.Status,
-			Progress:  task.Progress,
-			Total:     task.Total,
-			Message:   task.Message,
-			Result:    task.Result,
-			Error:     task.Error,
-			CreatedAt: task.CreatedAt,
-		}, true
+		return task.Task, true
+	}
+
+	var stored models.Task
+	if err := utils.DB.First(&stored, "id = ?", id).Error; err != nil {
+		return models.Task{}, false
 	}
-	return Task{}, false
+	return stored, true
+}
+
+// errTaskPaused is the cancellation cause of a paused task.
+var errTaskPaused = errors.New("task paused")
+
+// PauseTask cancels a running task so that it releases its LLM calls, and
+// marks it paused. ResumeTask continues it from the items it had finished.
+func (tm *TaskManager) PauseTask(id string) error {
+	val, ok := tm.tasks.Load(id)
+	if !ok {
+		return fmt.Errorf("task is not running")
+	}
+
+	task := val.(*Task)
+	task.mu.Lock()
+	defer task.mu.Unlock()
+
+	if task.Status != models.TaskStatusRunning && task.Status != models.TaskStatusPending {
+		return fmt.Errorf("task is %s and cannot be paused", task.Status)
+	}
+	task.cancel(errTaskPaused)
+	task.Status = models.TaskStatusPaused
+	task.Message = "Pausing"
+	if err := utils.DB.Save(&task.Task).Error; err != nil {
+		log.Printf("Failed to save task %s: %v", id, err)
+	}
+	snapshot := task.Task
+	task.publish(TaskEvent{Task: &snapshot})
+	return nil
 }
 
 func (tm *TaskManager) StopTask(id string) {
-	if val, ok := tm.tasks.Load(id); ok {
-		task := val.(*Task)
-		task.mu.Lock()
-		defer task.mu.Unlock()
+	val, ok := tm.tasks.Load(id)
+	if !ok {
+		// A paused task is not running, stopping it just makes it final
+		utils.DB.Model(&models.Task{}).Where("id = ? AND status = ?", id, models.TaskStatusPaused).
+			Updates(map[string]interface{}{"status": models.TaskStatusStopped, "message": "Stopped by user"})
+		return
+	}
 		
-		if task.Status == TaskStatusRunning || task.Status == TaskStatusPending {
-			task.cancel()
-			task.Status = TaskStatusStopped
-			task.Message = "Stopped by user"
+	task := val.(*Task)
+	task.mu.Lock()
+	defer task.mu.Unlock()
+
+	// A task still winding down from a pause is stopped instead of paused
+	if task.Status == models.TaskStatusRunning || task.Status == models.TaskStatusPending || task.Status == models.TaskStatusPaused {
+		task.cancel(nil)
+		task.Status = models.TaskStatusStopped
+		task.Message = "Stopped by user"
+		if err := utils.DB.Save(&task.Task).Error; err != nil {
+			log.Printf("Failed to save task %s: %v", id, err)
+		}
+		snapshot := task.Task
+		task.publish(TaskEvent{Task: &snapshot})
+	}
+}
+
+// MarkInterruptedTasks flags tasks left running by a previous process as
+// interrupted so they can be resumed. It must be called before any task is
+// started.
+func (tm *TaskManager) MarkInterruptedTasks() error {
+	return utils.DB.Model(&models.Task{}).
+		Where("status IN ?", []models.TaskStatus{models.TaskStatusPending, models.TaskStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":  models.TaskStatusInterrupted,
+			"message": "Interrupted by server restart",
+		}).Error
+}
+
+// janitorInterval is how often finished tasks are checked for expiry.
+const janitorInterval = time.Hour
+
+// StartJanitor deletes tasks that finished longer than retention ago, along
+// with their items, once now and then every hour. A zero retention keeps
+// tasks forever.
+func (tm *TaskManager) StartJanitor(retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	go func() {
+		for {
+			if n, err := tm.DeleteExpiredTasks(time.Now().Add(-retention)); err != nil {
+				log.Printf("Failed to delete expired tasks: %v", err)
+			} else if n > 0 {
+				log.Printf("Deleted %d expired tasks", n)
+			}
+			time.Sleep(janitorInterval)
+		}
+	}()
+}
+
+// DeleteExpiredTasks deletes tasks not running in this process that were
+// last updated before cutoff.
+func (tm *TaskManager) DeleteExpiredTasks(cutoff time.Time) (int64, error) {
+	var ids []string
+	err := utils.DB.Model(&models.Task{}).
+		Where("updated_at < ? AND status NOT IN ?", cutoff, []models.TaskStatus{models.TaskStatusPending, models.TaskStatusRunning}).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	err = utils.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id IN ?", ids).Delete(&models.TaskItem{}).Error; err != nil {
+			return err
 		}
+		return tx.Where("id IN ?", ids).Delete(&models.Task{}).Error
+	})
+	if err != nil {
+		return 0, err
 	}
+	return int64(len(ids)), nil
 }
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"codeagent-backend/utils"
+	"time"
+)
+
+type TaskService struct{}
+
+// TaskFilter narrows a task listing, empty fields match everything.
+type TaskFilter struct {
+	Status    string
+	Type      string
+	ProjectID string
+	PromptID  string
+	CreatedBy string
+	From      time.Time // Created at or after
+	To        time.Time // Created before
+}
+
+func (s *TaskService) GetTasks(filter TaskFilter, page, pageSize int) ([]models.Task, int64, error) {
+	var tasks []models.Task
+	var total int64
+
+	query := utils.DB.Model(&models.Task{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.ProjectID != "" {
+		query = query.Where("project_id = ?", filter.ProjectID)
+	}
+	if filter.PromptID != "" {
+		query = query.Where("prompt_id = ?", filter.PromptID)
+	}
+	if filter.CreatedBy != "" {
+		query = query.Where("created_by = ?", filter.CreatedBy)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	query.Count(&total)
+	err := query.Order("created_at desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&tasks).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Running tasks are more up to date in memory than in the database
+	for i := range tasks {
+		if task, ok := GlobalTaskManager.GetTask(tasks[i].ID); ok {
+			tasks[i] = task
+		}
+	}
+	return tasks, total, nil
+}
+
+// GetTaskItems lists the recorded items of a task in item order, optionally
+// only those with the given status.
+func (s *TaskService) GetTaskItems(taskID, status string, page, pageSize int) ([]models.TaskItem, int64, error) {
+	var items []models.TaskItem
+	var total int64
+
+	query := utils.DB.Model(&models.TaskItem{}).Where("task_id = ?", taskID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	query.Count(&total)
+	err := query.Order("item_index").Offset((page - 1) * pageSize).Limit(pageSize).Find(&items).Error
+	return items, total, err
+}
@@ -6,6 +6,8 @@ import (
 	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
 )
 
 type TestCaseService struct{}
@@ -15,8 +17,28 @@ func (s *TestCaseService) calculateMD5(text string) string {
 	return hex.EncodeToString(hash[:])
 }
 
+// inputMD5 hashes the input, plus the prior turns for conversation test cases
+// so the same final question in different conversations is not a duplicate.
+func (s *TestCaseService) inputMD5(input string, conversation []models.ChatTurn) string {
+	if len(conversation) == 0 {
+		return s.calculateMD5(input)
+	}
+	turns, _ := json.Marshal(conversation)
+	return s.calculateMD5(string(turns) + "\n" + input)
+}
+
+// ValidateConversation checks that every prior turn has a supported role
+func ValidateConversation(turns []models.ChatTurn) error {
+	for i, turn := range turns {
+		if turn.Role != models.ChatRoleUser && turn.Role != models.ChatRoleAssistant {
+			return fmt.Errorf("conversation turn %d has invalid role %q", i+1, turn.Role)
+		}
+	}
+	return nil
+}
+
 func (s *TestCaseService) CreateTestCase(testCase *models.TestCase) (bool, error) {
-	testCase.InputMD5 = s.calculateMD5(testCase.Input)
+	testCase.InputMD5 = s.inputMD5(testCase.Input, testCase.Conversation)
 
 	// Check for duplicates in the same project
 	if testCase.ProjectID != 0 {
@@ -57,9 +79,9 @@ func (s *TestCaseService) GetTestCase(id string) (*models.TestCase, error) {
 	return &testCase, err
 }
 
-func (s *TestCaseService) UpdateTestCase(testCase *models.TestCase, originalInput string) (bool, error) {
-	if testCase.Input != originalInput {
-		testCase.InputMD5 = s.calculateMD5(testCase.Input)
+func (s *TestCaseService) UpdateTestCase(testCase *models.TestCase, originalMD5 string) (bool, error) {
+	testCase.InputMD5 = s.inputMD5(testCase.Input, testCase.Conversation)
+	if testCase.InputMD5 != originalMD5 {
 		// Check uniqueness if input changed
 		if testCase.ProjectID != 0 {
 			var count int64
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"codeagent-backend/models"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// maxTaskWorkers bounds the worker count a request can ask for.
+const maxTaskWorkers = 32
+
+// taskWorkers resolves the worker count of a task: the count requested,
+// else the config's max concurrency, else one.
+func taskWorkers(requested int, config models.LLMConfig) int {
+	workers := requested
+	if workers <= 0 {
+		workers = config.MaxConcurrency
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > maxTaskWorkers {
+		workers = maxTaskWorkers
+	}
+	return workers
+}
+
+// runParallel calls fn for every index in [0, total) not yet completed in run,
+// on up to workers goroutines, and records the outcome of each item in run.
+// Progress is reported through updateProgress as the number of finished
+// items, prefixed with label. No new items are started once ctx is cancelled
+// or updateProgress fails, and items in flight see the cancelled ctx; items
+// interrupted that way are not recorded so that a resumed task runs them again.
+func runParallel(ctx context.Context, run *TaskRun, total, workers int, label string, updateProgress func(int, string) error, fn func(ctx context.Context, i int) (uint, error)) error {
+	if workers > total {
+		workers = total
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		done     = run.CompletedCount()
+		firstErr error
+	)
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+		cancel()
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				refID, itemErr, err := runItem(ctx, i, fn)
+				if err == nil && ctx.Err() == nil {
+					run.CompleteItem(i, refID, itemErr)
+				}
+
+				mu.Lock()
+				if err != nil {
+					fail(err)
+				} else if ctx.Err() == nil {
+					done++
+					if err := updateProgress(done, fmt.Sprintf("%s %d/%d", label, done, total)); err != nil {
+						fail(err)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < total; i++ {
+		if run.Completed(i) {
+			continue
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// runItem runs a single item, turning a panic into an error so that one bad
+// item fails the task instead of the whole process.
+func runItem(ctx context.Context, i int, fn func(ctx context.Context, i int) (uint, error)) (refID uint, itemErr error, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Panic: %v", r)
+		}
+	}()
+	refID, itemErr = fn(ctx, i)
+	return refID, itemErr, nil
+}
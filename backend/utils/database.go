@@ -17,7 +17,7 @@ func InitDB(dsn string) {
 	}
 
 	// Auto migrate
-	err = DB.AutoMigrate(&models.LLMConfig{}, &models.Project{}, &models.Prompt{}, &models.TestCase{}, &models.LLMTestCase{})
+	err = DB.AutoMigrate(&models.LLMConfig{}, &models.Project{}, &models.Prompt{}, &models.TestCase{}, &models.LLMTestCase{}, &models.ModelPrice{}, &models.Task{}, &models.TaskItem{}, &models.Pipeline{}, &models.Experiment{})
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
@@ -11,7 +11,52 @@ const taskStatus = ref({
   message: ''
 })
 
+// Shared by every component so a tab holds at most one event stream
+let eventSource = null
+
 export function useTask() {
+  const closeEvents = () => {
+    if (eventSource) {
+      eventSource.close()
+      eventSource = null
+    }
+  }
+
+  // Follows the current task over Server-Sent Events, falling back to
+  // polling if the stream can't be opened
+  const watchTask = () => {
+    closeEvents()
+    if (!currentTaskId.value) {
+      isRunning.value = false
+      return
+    }
+    if (typeof EventSource === 'undefined') {
+      pollStatus()
+      return
+    }
+
+    const source = new EventSource(`/api/tasks/${currentTaskId.value}/events`)
+    eventSource = source
+
+    const update = (event) => {
+      const task = JSON.parse(event.data)
+      taskStatus.value = task
+      isRunning.value = task.status === 'running' || task.status === 'pending'
+    }
+    source.addEventListener('progress', update)
+    source.addEventListener('done', (event) => {
+      update(event)
+      isRunning.value = false
+      closeEvents()
+    })
+    source.onerror = () => {
+      if (eventSource !== source) return
+      closeEvents()
+      // The stream ended without a final event or never opened
+      pollStatus()
+    }
+  }
+
   const pollStatus = async () => {
     if (!currentTaskId.value) {
       isRunning.value = false
@@ -43,7 +88,7 @@ export function useTask() {
     currentTaskId.value = id
     localStorage.setItem('current_llm_task_id', id)
     isRunning.value = true
-    pollStatus()
+    watchTask()
   }
 
   const stopRunningTask = async () => {
@@ -52,14 +97,43 @@ export function useTask() {
       await axios.post('/api/llm-test-cases/task/stop', {
         task_id: currentTaskId.value
       })
-      // Polling will update status to 'stopped'
+      // The event stream will update status to 'stopped'
     } catch (error) {
       console.error('Failed to stop task', error)
       throw error
     }
   }
 
+  // Stops the current task after its finished items; continueTask picks it up again
+  const pauseTask = async () => {
+    if (!currentTaskId.value) return
+    await axios.post('/api/llm-test-cases/task/pause', {
+      task_id: currentTaskId.value
+    })
+  }
+
+  // Runs a paused, interrupted, stopped or failed task again from where it ended
+  const continueTask = async () => {
+    if (!currentTaskId.value) return
+    await axios.post('/api/llm-test-cases/task/resume', {
+      task_id: currentTaskId.value
+    })
+    isRunning.value = true
+    watchTask()
+  }
+
+  // Runs only the items that failed in the current task again
+  const retryFailedItems = async () => {
+    if (!currentTaskId.value) return
+    await axios.post('/api/llm-test-cases/task/retry-failed', {
+      task_id: currentTaskId.value
+    })
+    isRunning.value = true
+    watchTask()
+  }
+
   const clearTask = () => {
+    closeEvents()
     currentTaskId.value = ''
     localStorage.removeItem('current_llm_task_id')
     taskStatus.value = { status: '', progress: 0, total: 0, message: '' }
@@ -68,7 +142,7 @@ export function useTask() {
   
   const resumeTask = () => {
     if (currentTaskId.value) {
-      pollStatus()
+      watchTask()
     }
   }
 
@@ -78,6 +152,9 @@ export function useTask() {
     taskStatus,
     startTask,
     stopRunningTask,
+    pauseTask,
+    continueTask,
+    retryFailedItems,
     clearTask,
     resumeTask
   }
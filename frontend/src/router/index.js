@@ -33,6 +33,11 @@ const router = createRouter({
       path: '/llm-test-cases',
       name: 'llm-test-cases',
       component: () => import('../views/LLMTestCaseView.vue')
+    },
+    {
+      path: '/tasks',
+      name: 'tasks',
+      component: () => import('../views/TaskView.vue')
     }
   ]
 })